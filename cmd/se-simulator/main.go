@@ -0,0 +1,120 @@
+/*
+Copyright 2023 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command se-simulator serves a synthetic, ever-changing Space Engineers world
+// on a fake remote API, to develop dashboards and alerts against the exporter
+// without a dedicated server.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/promlog"
+	"github.com/prometheus/common/promlog/flag"
+	"github.com/prometheus/common/version"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+	"github.com/thelande/space-engineers-exporter/pkg/vrage_client/vragetest"
+)
+
+const simulatorName = "se_simulator"
+
+var (
+	listenAddress = kingpin.Flag(
+		"web.listen-address",
+		"Address on which to serve the remote API.",
+	).Default(":8080").String()
+
+	key = kingpin.Flag(
+		"remote-api.key",
+		"The secret key the clients of the remote API must use.",
+	).Envar("SE_REMOTE_API_KEY").Required().String()
+
+	latency = kingpin.Flag(
+		"remote-api.latency",
+		"Time to wait before answering each request.",
+	).Default("0s").Duration()
+
+	tick = kingpin.Flag(
+		"simulation.tick",
+		"Interval at which the world is updated.",
+	).Default("1s").Duration()
+
+	maxPlayers = kingpin.Flag(
+		"simulation.max-players",
+		"Maximum number of players online at the same time.",
+	).Default("16").Int()
+
+	maxGrids = kingpin.Flag(
+		"simulation.max-grids",
+		"Maximum number of grids in the world.",
+	).Default("200").Int()
+
+	seed = kingpin.Flag(
+		"simulation.seed",
+		"Seed of the random number generator. A seed based on the current time is used when 0.",
+	).Default("0").Int64()
+)
+
+func main() {
+	promlogConfig := &promlog.Config{}
+	flag.AddFlags(kingpin.CommandLine, promlogConfig)
+	kingpin.CommandLine.UsageWriter(os.Stdout)
+	kingpin.HelpFlag.Short('h')
+	kingpin.Version(version.Print(simulatorName))
+	kingpin.Parse()
+
+	logger := promlog.New(promlogConfig)
+	level.Info(logger).Log("msg", fmt.Sprintf("Starting %s", simulatorName), "version", version.Info())
+
+	if *tick <= 0 {
+		level.Error(logger).Log("msg", "Invalid simulation tick, it must be positive", "tick", *tick)
+		os.Exit(1)
+	}
+
+	secret, err := vrage_client.DecodeSecretKey(*key)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to decode the remote API key", "err", err)
+		os.Exit(1)
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	level.Info(logger).Log("msg", "Creating world", "seed", *seed)
+
+	handler := vragetest.NewHandler(secret)
+	handler.SetGlobalFault(vragetest.Fault{Latency: *latency})
+
+	w := newWorld(worldOptions{MaxPlayers: *maxPlayers, MaxGrids: *maxGrids}, *seed)
+	w.publish(handler)
+	go func() {
+		for now := range time.Tick(*tick) {
+			w.tick(now, *tick)
+			w.publish(handler)
+		}
+	}()
+
+	level.Info(logger).Log("msg", "Listening on", "address", *listenAddress)
+	if err := http.ListenAndServe(*listenAddress, handler); err != nil {
+		level.Error(logger).Log("msg", "HTTP listener stopped", "err", err)
+		os.Exit(1)
+	}
+}
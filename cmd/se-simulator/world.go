@@ -0,0 +1,410 @@
+/*
+Copyright 2023 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+	"github.com/thelande/space-engineers-exporter/pkg/vrage_client/vragetest"
+)
+
+const (
+	// The number of chat messages kept by the simulated server.
+	chatHistorySize = 200
+	// The number of cheater detections kept by the simulated server.
+	cheaterHistorySize = 50
+)
+
+var (
+	factions = []struct{ name, tag string }{
+		{"", ""},
+		{"Builders Union", "BLD"},
+		{"Deep Space Miners", "DSM"},
+		{"Red Fleet", "RED"},
+	}
+
+	chatLines = []string{
+		"anyone got spare uranium?",
+		"lag is terrible right now",
+		"heading to the moon",
+		"who parked a carrier on my base",
+		"selling steel plates",
+		"gg",
+	}
+
+	gridPrefixes = []string{"Miner", "Hauler", "Fighter", "Station", "Rover", "Drone"}
+
+	floatingItems = []struct {
+		name, kind string
+		mass       float64
+	}{
+		{"Iron Ore", "Ore", 500},
+		{"Ice", "Ore", 400},
+		{"Stone", "Ore", 300},
+		{"Steel Plate", "Component", 20},
+		{"Construction Comp.", "Component", 8},
+		{"Welder", "Tool", 5},
+	}
+
+	cheatExplanations = []string{"Speed hack", "Inventory exploit", "Invalid block placement"}
+)
+
+// A simulated player. Online players have a character and may own grids.
+// Banned and kicked players cannot join until they are removed from the
+// list.
+type simPlayer struct {
+	vrage_client.OnlinePlayerResponseData
+	online   bool
+	banned   bool
+	kicked   bool
+	position vrage_client.EntityPosition
+}
+
+// A simulated grid, moving at a constant velocity.
+type simGrid struct {
+	vrage_client.GridResponseData
+	velocity vrage_client.EntityPosition
+}
+
+// Options of the simulated world.
+type worldOptions struct {
+	MaxPlayers int
+	MaxGrids   int
+}
+
+// A synthetic world that changes over time: players join and leave, grids
+// spawn, move and get removed, the simulation speed drops under load, the
+// players chat and drop items, and the admins ban and kick players caught
+// cheating.
+type world struct {
+	options worldOptions
+	rand    *rand.Rand
+
+	mu           sync.Mutex
+	totalTime    float64
+	nextEntityId int64
+	players      []*simPlayer
+	grids        []*simGrid
+	chat         []vrage_client.ChatMessageResponseData
+	floating     []vrage_client.FloatingObjectResponseData
+	cheaters     []vrage_client.CheaterResponseData
+	simSpeed     float64
+	planets      []vrage_client.PlanetResponseData
+	asteroids    []vrage_client.AsteroidResponseData
+
+	// The identifier of the next cheater detection.
+	nextCheaterId int
+}
+
+func newWorld(options worldOptions, seed int64) *world {
+	w := &world{
+		options:      options,
+		rand:         rand.New(rand.NewSource(seed)),
+		nextEntityId: 1000,
+		simSpeed:     1,
+		floating:     []vrage_client.FloatingObjectResponseData{},
+		cheaters:     []vrage_client.CheaterResponseData{},
+		planets: []vrage_client.PlanetResponseData{
+			{DisplayName: "EarthLike", EntityId: 1, Position: vrage_client.EntityPosition{X: 0, Y: 0, Z: 0}},
+			{DisplayName: "Moon", EntityId: 2, Position: vrage_client.EntityPosition{X: 16384, Y: 136384, Z: -113615}},
+			{DisplayName: "Mars", EntityId: 3, Position: vrage_client.EntityPosition{X: 1031072, Y: 131072, Z: 1631072}},
+		},
+	}
+
+	for i := 0; i < 20; i++ {
+		w.asteroids = append(w.asteroids, vrage_client.AsteroidResponseData{
+			DisplayName: fmt.Sprintf("Asteroid %d", i),
+			EntityId:    int64(100 + i),
+			Position:    w.randomPosition(w.planets[w.rand.Intn(len(w.planets))].Position, 200000),
+		})
+	}
+
+	for i := 0; i < options.MaxPlayers*2; i++ {
+		faction := factions[w.rand.Intn(len(factions))]
+		w.players = append(w.players, &simPlayer{
+			OnlinePlayerResponseData: vrage_client.OnlinePlayerResponseData{
+				SteamID:     76561198000000000 + uint64(i),
+				DisplayName: fmt.Sprintf("Engineer %d", i+1),
+				FactionName: faction.name,
+				FactionTag:  faction.tag,
+			},
+		})
+	}
+
+	return w
+}
+
+// Returns a random position within the given distance of the given position.
+func (w *world) randomPosition(center vrage_client.EntityPosition, spread float64) vrage_client.EntityPosition {
+	return vrage_client.EntityPosition{
+		X: center.X + (w.rand.Float64()*2-1)*spread,
+		Y: center.Y + (w.rand.Float64()*2-1)*spread,
+		Z: center.Z + (w.rand.Float64()*2-1)*spread,
+	}
+}
+
+// Advance the world by the given time, up to the given date.
+func (w *world) tick(now time.Time, dt time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seconds := dt.Seconds()
+	w.totalTime += seconds * w.simSpeed
+
+	online := 0
+	for _, p := range w.players {
+		if p.online {
+			online++
+		}
+	}
+
+	for _, p := range w.players {
+		switch {
+		case p.online && w.rand.Float64() < 0.01*seconds:
+			p.online = false
+			online--
+		case !p.online && !p.banned && !p.kicked && online < w.options.MaxPlayers && w.rand.Float64() < 0.02*seconds:
+			p.online = true
+			p.position = w.randomPosition(w.planets[w.rand.Intn(len(w.planets))].Position, 70000)
+			online++
+		}
+		if p.online {
+			p.Ping = 20 + w.rand.Intn(150)
+			p.position = w.randomPosition(p.position, 20*seconds)
+			if w.rand.Float64() < 0.02*seconds {
+				w.chat = append(w.chat, vrage_client.ChatMessageResponseData{
+					SteamID:     p.SteamID,
+					DisplayName: p.DisplayName,
+					Content:     chatLines[w.rand.Intn(len(chatLines))],
+					Timestamp:   now.UnixMilli(),
+				})
+			}
+		}
+	}
+	if len(w.chat) > chatHistorySize {
+		w.chat = append([]vrage_client.ChatMessageResponseData(nil), w.chat[len(w.chat)-chatHistorySize:]...)
+	}
+
+	w.tickAdmin(seconds, now)
+	w.tickFloating(seconds)
+
+	// Online players spawn grids, which are removed at random.
+	grids := w.grids[:0]
+	for _, g := range w.grids {
+		if w.rand.Float64() < 0.002*seconds {
+			continue
+		}
+		g.Position.X += g.velocity.X * seconds
+		g.Position.Y += g.velocity.Y * seconds
+		g.Position.Z += g.velocity.Z * seconds
+		g.LinearSpeed = math.Sqrt(g.velocity.X*g.velocity.X + g.velocity.Y*g.velocity.Y + g.velocity.Z*g.velocity.Z)
+		grids = append(grids, g)
+	}
+	w.grids = grids
+	for _, p := range w.players {
+		if p.online && len(w.grids) < w.options.MaxGrids && w.rand.Float64() < 0.01*seconds {
+			w.grids = append(w.grids, w.newGrid(p))
+		}
+	}
+
+	// The simulation slows down as the PCU usage grows.
+	load := float64(w.usedPCU()) / float64(w.options.MaxGrids*2000+1)
+	target := math.Min(1, 1.2-load) + (w.rand.Float64()-0.5)*0.05
+	w.simSpeed = math.Max(0.1, math.Min(1, 0.8*w.simSpeed+0.2*target))
+}
+
+// Catch cheaters among the online players, kick and ban players, and lift the
+// kicks and bans.
+func (w *world) tickAdmin(seconds float64, now time.Time) {
+	for _, p := range w.players {
+		switch {
+		case p.banned:
+			p.banned = w.rand.Float64() >= 0.002*seconds
+		case p.kicked:
+			p.kicked = w.rand.Float64() >= 0.01*seconds
+		case p.online && w.rand.Float64() < 0.0005*seconds:
+			w.cheaters = append(w.cheaters, vrage_client.CheaterResponseData{
+				Explanation:    cheatExplanations[w.rand.Intn(len(cheatExplanations))],
+				Id:             w.nextCheaterId,
+				Name:           p.DisplayName,
+				PlayerId:       p.SteamID,
+				ServerDateTime: now.Format("2006-01-02T15:04:05"),
+			})
+			w.nextCheaterId++
+			p.online = false
+			p.banned = true
+		case p.online && w.rand.Float64() < 0.001*seconds:
+			p.online = false
+			p.kicked = true
+		}
+	}
+	if len(w.cheaters) > cheaterHistorySize {
+		w.cheaters = append([]vrage_client.CheaterResponseData(nil), w.cheaters[len(w.cheaters)-cheaterHistorySize:]...)
+	}
+}
+
+// Online players drop items, which drift and despawn at random.
+func (w *world) tickFloating(seconds float64) {
+	floating := w.floating[:0]
+	for _, o := range w.floating {
+		if w.rand.Float64() < 0.01*seconds {
+			continue
+		}
+		o.Position = w.randomPosition(o.Position, o.LinearSpeed*seconds)
+		floating = append(floating, o)
+	}
+	w.floating = floating
+
+	for _, p := range w.players {
+		if p.online && w.rand.Float64() < 0.02*seconds {
+			item := floatingItems[w.rand.Intn(len(floatingItems))]
+			w.nextEntityId++
+			w.floating = append(w.floating, vrage_client.FloatingObjectResponseData{
+				DisplayName:      item.name,
+				EntityId:         w.nextEntityId,
+				Kind:             item.kind,
+				Mass:             item.mass * float64(1+w.rand.Intn(10)),
+				Position:         w.randomPosition(p.position, 5),
+				LinearSpeed:      w.rand.Float64() * 2,
+				DistanceToPlayer: w.rand.Float64() * 5,
+			})
+		}
+	}
+}
+
+// Returns a new grid owned by the given player, near the player.
+func (w *world) newGrid(owner *simPlayer) *simGrid {
+	w.nextEntityId++
+	blocks := 10 + w.rand.Intn(3000)
+	large := w.rand.Intn(2) == 0
+	size := "Small"
+	if large {
+		size = "Large"
+	}
+
+	g := &simGrid{
+		GridResponseData: vrage_client.GridResponseData{
+			DisplayName:      fmt.Sprintf("%s %d", gridPrefixes[w.rand.Intn(len(gridPrefixes))], w.nextEntityId),
+			EntityId:         w.nextEntityId,
+			GridSize:         size,
+			BlocksCount:      uint(blocks),
+			Mass:             float64(blocks) * (50 + w.rand.Float64()*500),
+			Position:         w.randomPosition(owner.position, 1000),
+			OwnerSteamId:     owner.SteamID,
+			OwnerDisplayName: owner.DisplayName,
+			IsPowered:        w.rand.Float64() < 0.9,
+			PCU:              uint(blocks) * uint(1+w.rand.Intn(5)),
+		},
+	}
+	// Stations do not move.
+	if w.rand.Float64() < 0.7 {
+		g.velocity = w.randomPosition(vrage_client.EntityPosition{}, 60)
+	}
+	return g
+}
+
+func (w *world) usedPCU() uint {
+	var pcu uint
+	for _, g := range w.grids {
+		pcu += g.PCU
+	}
+	return pcu
+}
+
+// Publish the current state of the world on the given fake remote API.
+func (w *world) publish(h *vragetest.Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	players := []vrage_client.OnlinePlayerResponseData{}
+	characters := []vrage_client.CharacterResponseData{}
+	for _, p := range w.players {
+		if !p.online {
+			continue
+		}
+		players = append(players, p.OnlinePlayerResponseData)
+		characters = append(characters, vrage_client.CharacterResponseData{
+			DisplayName: p.DisplayName,
+			EntityId:    int64(p.SteamID % 1000000),
+			Mass:        100,
+			Position:    p.position,
+			LinearSpeed: w.rand.Float64() * 10,
+		})
+	}
+
+	grids := []vrage_client.GridResponseData{}
+	for _, g := range w.grids {
+		grids = append(grids, g.GridResponseData)
+	}
+
+	banned := []vrage_client.PlayerResponseData{}
+	kicked := []vrage_client.PlayerResponseData{}
+	for _, p := range w.players {
+		entry := vrage_client.PlayerResponseData{SteamID: p.SteamID, DisplayName: p.DisplayName}
+		if p.banned {
+			banned = append(banned, entry)
+		}
+		if p.kicked {
+			kicked = append(kicked, entry)
+		}
+	}
+
+	h.MustSetData("GET", "/v1/server", vrage_client.ServerResponseData{
+		IsReady:           true,
+		Players:           len(players),
+		ServerId:          1,
+		ServerName:        "se-simulator",
+		SimSpeed:          w.simSpeed,
+		SimulationCpuLoad: (1 - w.simSpeed) * 100,
+		TotalTime:         uint(w.totalTime),
+		UsedPCU:           w.usedPCU(),
+		Version:           "1.204.18",
+		WorldName:         "Simulated World",
+	})
+	h.MustSetData("GET", "/v1/session/players", map[string]interface{}{"Players": players})
+	h.MustSetData("GET", "/v1/session/characters", map[string]interface{}{"Characters": characters})
+	h.MustSetData("GET", "/v1/session/grids", map[string]interface{}{"Grids": grids})
+	h.MustSetData("GET", "/v1/session/planets", map[string]interface{}{"Planets": w.planets})
+	h.MustSetData("GET", "/v1/session/asteroids", map[string]interface{}{"Asteroids": w.asteroids})
+	h.MustSetData("GET", "/v1/session/floatingObjects", map[string]interface{}{"FloatingObjects": w.floating})
+	h.MustSetData("GET", "/v1/admin/bannedPlayers", map[string]interface{}{"BannedPlayers": banned})
+	h.MustSetData("GET", "/v1/admin/kickedPlayers", map[string]interface{}{"KickedPlayers": kicked})
+	h.MustSetData("GET", "/v1/admin/cheaters", map[string]interface{}{"Cheaters": w.cheaters})
+
+	// The chat endpoint only returns the messages sent after the given date.
+	chat := append([]vrage_client.ChatMessageResponseData(nil), w.chat...)
+	h.SetFunc("GET", "/v1/session/chat", func(r *http.Request) vragetest.Fixture {
+		since, _ := strconv.ParseInt(r.URL.Query().Get("Date"), 10, 64)
+		messages := []vrage_client.ChatMessageResponseData{}
+		for _, msg := range chat {
+			if msg.Timestamp > since {
+				messages = append(messages, msg)
+			}
+		}
+		fixture, err := vragetest.NewFixture("GET", "/v1/session/chat", map[string]interface{}{"Messages": messages})
+		if err != nil {
+			return vragetest.Fixture{StatusCode: http.StatusInternalServerError}
+		}
+		return fixture
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+	"github.com/thelande/space-engineers-exporter/pkg/vrage_client/vragetest"
+)
+
+// Returns a world created with the given seed, after an hour of simulation.
+func simulate(seed int64) *world {
+	w := newWorld(worldOptions{MaxPlayers: 10, MaxGrids: 20}, seed)
+	start := time.Unix(1700000000, 0)
+	for i := 1; i <= 360; i++ {
+		w.tick(start.Add(time.Duration(i)*10*time.Second), 10*time.Second)
+	}
+	return w
+}
+
+func TestWorldDeterminism(t *testing.T) {
+	a, b := simulate(42), simulate(42)
+
+	if len(a.grids) == 0 || len(a.chat) == 0 || len(a.floating) == 0 {
+		t.Fatalf("expected grids, chat messages and floating objects, got %d, %d and %d", len(a.grids), len(a.chat), len(a.floating))
+	}
+	if !reflect.DeepEqual(a.players, b.players) {
+		t.Error("expected the same players for the same seed")
+	}
+	if !reflect.DeepEqual(a.grids, b.grids) {
+		t.Error("expected the same grids for the same seed")
+	}
+	if !reflect.DeepEqual(a.chat, b.chat) {
+		t.Error("expected the same chat messages for the same seed")
+	}
+	if !reflect.DeepEqual(a.floating, b.floating) {
+		t.Error("expected the same floating objects for the same seed")
+	}
+	if !reflect.DeepEqual(a.cheaters, b.cheaters) {
+		t.Error("expected the same cheaters for the same seed")
+	}
+	if a.simSpeed != b.simSpeed || a.totalTime != b.totalTime {
+		t.Errorf("expected the same simulation speed and time, got %v/%v and %v/%v", a.simSpeed, a.totalTime, b.simSpeed, b.totalTime)
+	}
+
+	if c := simulate(43); reflect.DeepEqual(a.grids, c.grids) {
+		t.Error("expected different grids for different seeds")
+	}
+}
+
+func TestWorldEndpoints(t *testing.T) {
+	key := []byte("se-simulator secret key")
+	h := vragetest.NewHandler(key)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	w := simulate(42)
+	w.publish(h)
+
+	logger := log.NewNopLogger()
+	client, err := vrage_client.NewVRageClient(srv.URL, "", base64.StdEncoding.EncodeToString(key), true, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	grids, err := client.GetGrids(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grids.Data.Grids) != len(w.grids) {
+		t.Errorf("expected %d grids, got %d", len(w.grids), len(grids.Data.Grids))
+	}
+	if _, err := client.GetPlayers(ctx); err != nil {
+		t.Error(err)
+	}
+	floating, err := client.GetFloatingObjects(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(floating.Data.FloatingObjects) != len(w.floating) {
+		t.Errorf("expected %d floating objects, got %d", len(w.floating), len(floating.Data.FloatingObjects))
+	}
+	chat, err := client.GetChatMessages(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chat.Data.Messages) != len(w.chat) {
+		t.Errorf("expected %d chat messages, got %d", len(w.chat), len(chat.Data.Messages))
+	}
+	if _, err := client.GetBannedPlayers(ctx); err != nil {
+		t.Error(err)
+	}
+	if _, err := client.GetKickedPlayers(ctx); err != nil {
+		t.Error(err)
+	}
+	cheaters, err := client.GetCheaters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cheaters.Data.Cheaters) != len(w.cheaters) {
+		t.Errorf("expected %d cheaters, got %d", len(w.cheaters), len(cheaters.Data.Cheaters))
+	}
+
+	// Requests signed with another key are rejected.
+	other, err := vrage_client.NewVRageClient(srv.URL, "", base64.StdEncoding.EncodeToString([]byte("another key")), true, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statusErr *vrage_client.StatusError
+	if _, err := other.GetGrids(ctx); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status 403, got %v", err)
+	}
+}
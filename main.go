@@ -20,15 +20,18 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	kingpin "github.com/alecthomas/kingpin/v2"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/thelande/space-engineers-exporter/pkg/collector"
-	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
 
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/promlog"
 	"github.com/prometheus/common/promlog/flag"
 	"github.com/prometheus/common/version"
@@ -42,6 +45,11 @@ const (
 )
 
 var (
+	configFile = kingpin.Flag(
+		"config.file",
+		"Path of the YAML configuration file defining the servers to collect metrics from and the modules available to the probe endpoint.",
+	).String()
+
 	api = kingpin.Flag(
 		"remote-api.url",
 		"URL of the remote API",
@@ -62,14 +70,241 @@ var (
 		"Verify the remote API SSL certificate, when true.",
 	).Default("true").Bool()
 
+	apiTimeout = kingpin.Flag(
+		"remote-api.timeout",
+		"Maximum time to spend querying the remote API per background poll or per scrape.",
+	).Default("10s").Duration()
+
+	pollInterval = kingpin.Flag(
+		"remote-api.poll-interval",
+		"Poll the remote API in the background at this interval and serve the latest snapshot on scrapes. Disabled when 0.",
+	).Default("0s").Duration()
+
+	retries = kingpin.Flag(
+		"remote-api.retries",
+		"Number of times a failed query is retried when the remote API cannot be reached or responds with a 5xx status code.",
+	).Default("2").Int()
+
+	retryBackoff = kingpin.Flag(
+		"remote-api.retry-backoff",
+		"Maximum delay before the first retry of a query, doubled at each retry.",
+	).Default("100ms").Duration()
+
+	retryMaxBackoff = kingpin.Flag(
+		"remote-api.retry-max-backoff",
+		"Maximum delay between two retries of a query.",
+	).Default("2s").Duration()
+
+	circuitBreakerThreshold = kingpin.Flag(
+		"remote-api.circuit-breaker-threshold",
+		"Number of consecutive failed queries after which the remote API is no longer queried for a while. Disabled when 0.",
+	).Default("5").Int()
+
+	circuitBreakerCooldown = kingpin.Flag(
+		"remote-api.circuit-breaker-cooldown",
+		"Time during which the remote API is not queried once the circuit breaker opens.",
+	).Default("30s").Duration()
+
+	recordDir = kingpin.Flag(
+		"remote-api.record-dir",
+		"Record the responses of the remote API of each server as fixtures in a subdirectory of this directory.",
+	).String()
+
+	replayDir = kingpin.Flag(
+		"remote-api.replay-dir",
+		"Serve the fixtures recorded with --remote-api.record-dir in this directory instead of querying the remote API.",
+	).String()
+
+	maxConcurrency = kingpin.Flag(
+		"remote-api.max-concurrency",
+		"Maximum number of collectors querying the remote API at the same time.",
+	).Default("4").Int()
+
+	timeoutOffset = kingpin.Flag(
+		"web.timeout-offset",
+		"Offset to subtract from the timeout specified by Prometheus, to leave time for the response to be sent.",
+	).Default("0.5s").Duration()
+
 	metricsPath = kingpin.Flag(
 		"web.telemetry-path",
 		"Path under which to expose metrics.",
 	).Default("/metrics").String()
+
+	chatPath = kingpin.Flag(
+		"web.chat-path",
+		"Path under which to expose the recent chat messages as JSON.",
+	).Default("/chat").String()
+
+	eventsPath = kingpin.Flag(
+		"web.events-path",
+		"Path under which to expose the recent changes of the banned, kicked and cheater lists as JSON.",
+	).Default("/events").String()
+
+	enableLifecycle = kingpin.Flag(
+		"web.enable-lifecycle",
+		"Enable the /-/reload endpoint, which reloads the configuration file when it receives a POST request.",
+	).Default("false").Bool()
+
+	enableSave = kingpin.Flag(
+		"web.enable-save",
+		"Enable the /-/save endpoint, which saves the world when it receives a POST request.",
+	).Default("false").Bool()
+
+	probeMaxTargets = kingpin.Flag(
+		"probe.max-targets",
+		"Maximum number of targets of the probe endpoint whose state is kept. The least recently probed target is forgotten, along with its player session state and recorded responses, when a new target is probed.",
+	).Default("100").Int()
+
+	collectorFlags = addCollectorFlags()
+
+	playersStateDir = kingpin.Flag(
+		"collector.players.state-dir",
+		"Directory in which the session history of the players of each server is kept across restarts. The history is not persisted when empty.",
+	).String()
+
+	playersMaxSessionGap = kingpin.Flag(
+		"collector.players.max-session-gap",
+		"Longest time between two queries of the players credited to the players connected at both. A player seen again after a longer time, for example after failed scrapes, starts a new session.",
+	).Default("5m").Duration()
+
+	gridsTopN = kingpin.Flag(
+		"collector.grids.top-n",
+		"Maximum number of grids exported with per-grid metrics. Per-grid metrics are disabled when 0.",
+	).Default("20").Int()
+
+	gridsRankBy = kingpin.Flag(
+		"collector.grids.rank-by",
+		"Export the per-grid metrics of the grids with the highest value of this property.",
+	).Default(collector.GridRankByPCU).Enum(collector.GridRankByPCU, collector.GridRankByMass)
+
+	gridsNameInclude = kingpin.Flag(
+		"collector.grids.name-include",
+		"Regexp of the grid names to export per-grid metrics for.",
+	).Regexp()
+
+	gridsNameExclude = kingpin.Flag(
+		"collector.grids.name-exclude",
+		"Regexp of the grid names not to export per-grid metrics for.",
+	).Regexp()
+
+	factionsPcuBudget = kingpin.Flag(
+		"collector.factions.pcu-budget",
+		"Number of PCUs each faction is allowed to use. The PCU budget of the factions is not exported when 0.",
+	).Default("0").Uint()
+
+	factionsPcuBudgets = kingpin.Flag(
+		"collector.factions.pcu-budget-override",
+		"PCU budget of a specific faction, as TAG=PCU. May be repeated.",
+	).StringMap()
+
+	sectorsSize = kingpin.Flag(
+		"collector.sectors.size",
+		"Edge length of the cubic sectors the world is divided into, in kilometres.",
+	).Default("100").Float64()
+
+	sectorsPlanetDistances = kingpin.Flag(
+		"collector.sectors.planet-distance",
+		"Distance from the centre of the planets, in kilometres, within which the grids are aggregated. May be repeated.",
+	).Default("60", "120", "250").Float64List()
+
 	webConfig = webflag.AddFlags(kingpin.CommandLine, ":9815")
 	logger    log.Logger
 )
 
+// Add the --collector.<name> flags enabling or disabling each collector. The
+// flags are negated with --no-collector.<name>.
+func addCollectorFlags() map[string]*bool {
+	flags := make(map[string]*bool)
+	for _, name := range collector.Names() {
+		defaultState := "disabled"
+		if collector.DefaultEnabled(name) {
+			defaultState = "enabled"
+		}
+		flags[name] = kingpin.Flag(
+			fmt.Sprintf("collector.%s", name),
+			fmt.Sprintf("Enable the %s collector (default: %s).", name, defaultState),
+		).Default(strconv.FormatBool(collector.DefaultEnabled(name))).Bool()
+	}
+	return flags
+}
+
+// Returns the names of the collectors enabled on the command line.
+func enabledCollectors() []string {
+	enabled := []string{}
+	for _, name := range collector.Names() {
+		if *collectorFlags[name] {
+			enabled = append(enabled, name)
+		}
+	}
+	return enabled
+}
+
+// Returns the PCU budgets of the factions given on the command line, by tag.
+func factionPcuBudgets() (map[string]uint, error) {
+	budgets := make(map[string]uint, len(*factionsPcuBudgets))
+	for tag, value := range *factionsPcuBudgets {
+		budget, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PCU budget %q for faction %q: %w", value, tag, err)
+		}
+		budgets[tag] = uint(budget)
+	}
+	return budgets, nil
+}
+
+// Returns the path of the file or directory named after the server with the
+// given name in the given directory. The bytes of the name other than letters,
+// digits, '_', '-' and '.' are escaped as %XX, as is a leading '.', so that
+// distinct names never share a path.
+func serverPath(dir string, name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-' || c == '.' && i > 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return filepath.Join(dir, b.String())
+}
+
+// Returns the path of the file keeping the player session history of the
+// server with the given name, or an empty string if the history is not
+// persisted.
+func playerStateFile(name string) string {
+	if *playersStateDir == "" {
+		return ""
+	}
+	return serverPath(*playersStateDir, name) + ".json"
+}
+
+// Returns the time allowed for collecting the metrics of the given request.
+// Prometheus advertises its scrape timeout in a header, which is used minus the
+// timeout offset, or as is when it does not exceed the offset. The result is
+// capped at the configured remote API timeout, which is also used when the
+// header is missing or not positive.
+func getScrapeTimeout(r *http.Request) (time.Duration, error) {
+	v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if v == "" {
+		return *apiTimeout, nil
+	}
+
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse timeout from Prometheus header: %w", err)
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > *timeoutOffset {
+		timeout -= *timeoutOffset
+	}
+	if timeout <= 0 || timeout > *apiTimeout {
+		timeout = *apiTimeout
+	}
+	return timeout, nil
+}
+
 func main() {
 	promlogConfig := &promlog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promlogConfig)
@@ -82,23 +317,80 @@ func main() {
 	level.Info(logger).Log("msg", fmt.Sprintf("Starting %s", exporterName), "version", version.Info())
 	level.Info(logger).Log("msg", "Build context", "build_context", version.BuildContext())
 
-	client, err := vrage_client.NewVRageClient(*api, *keyFile, *key, *sslVerify, &logger)
+	if *recordDir != "" && *replayDir != "" {
+		level.Error(logger).Log("msg", "--remote-api.record-dir and --remote-api.replay-dir are mutually exclusive")
+		os.Exit(1)
+	}
+
+	budgets, err := factionPcuBudgets()
 	if err != nil {
+		level.Error(logger).Log("msg", "Invalid faction PCU budget", "err", err)
+		os.Exit(1)
+	}
+
+	if *sectorsSize <= 0 {
+		level.Error(logger).Log("msg", "Invalid sector size", "size", *sectorsSize)
+		os.Exit(1)
+	}
+
+	if *pollInterval > 0 && *playersMaxSessionGap <= *pollInterval {
+		level.Error(logger).Log("msg", "--collector.players.max-session-gap must exceed --remote-api.poll-interval", "max_session_gap", *playersMaxSessionGap, "poll_interval", *pollInterval)
+		os.Exit(1)
+	}
+
+	options := collector.Options{
+		MaxConcurrency: *maxConcurrency,
+		Collectors:     enabledCollectors(),
+		Players: collector.PlayerOptions{
+			MaxSessionGap: *playersMaxSessionGap,
+		},
+		Grids: collector.GridOptions{
+			TopN:        *gridsTopN,
+			RankBy:      *gridsRankBy,
+			NameInclude: *gridsNameInclude,
+			NameExclude: *gridsNameExclude,
+		},
+		Factions: collector.FactionOptions{
+			PCUBudget:  *factionsPcuBudget,
+			PCUBudgets: budgets,
+		},
+		Sectors: collector.SectorOptions{
+			SizeKm:            *sectorsSize,
+			PlanetDistancesKm: *sectorsPlanetDistances,
+		},
+	}
+	level.Info(logger).Log("msg", "Enabled collectors", "collectors", strings.Join(options.Collectors, ","))
+	if *probeMaxTargets < 1 {
+		level.Error(logger).Log("msg", "Invalid maximum number of probe targets", "max_targets", *probeMaxTargets)
+		os.Exit(1)
+	}
+
+	probe := newProbeHandler(options, *probeMaxTargets)
+	targets := &targetSet{options: options, probe: probe}
+	if err := targets.reload(); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			level.Error(logger).Log("msg", "Secret key file not found", "path", *keyFile)
+			level.Error(logger).Log("msg", "File not found", "err", err)
 		} else {
-			level.Error(logger).Log("msg", "Unknown error occurred while creating VRage client", "err", err)
+			level.Error(logger).Log("msg", "Failed to load configuration", "err", err)
 		}
 		os.Exit(1)
 	}
+	if *pollInterval > 0 {
+		level.Info(logger).Log("msg", "Polling the remote API in the background", "interval", *pollInterval)
+	}
 
-	collector := collector.NewCollector(client, logger)
-
-	// Uncomment the following two lines and comment out prometheus.MustRegister(collector)
-	// to exclude the go metrics. Make sure to swap line 88 and 89 as well.
-	registry := prometheus.NewRegistry()
-	registry.MustRegister(collector)
-	// prometheus.MustRegister(collector)
+	// Reload the configuration when receiving SIGHUP.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := targets.reload(); err != nil {
+				level.Error(logger).Log("msg", "Failed to reload configuration", "err", err)
+				continue
+			}
+			level.Info(logger).Log("msg", "Reloaded configuration")
+		}
+	}()
 
 	landingConfig := web.LandingConfig{
 		Name:        exporterTitle,
@@ -109,6 +401,14 @@ func main() {
 				Address: *metricsPath,
 				Text:    "Metrics",
 			},
+			{
+				Address: *chatPath,
+				Text:    "Chat Messages",
+			},
+			{
+				Address: *eventsPath,
+				Text:    "Admin List Events",
+			},
 		},
 	}
 	landingPage, err := web.NewLandingPage(landingConfig)
@@ -117,8 +417,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	http.Handle(*metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
-	// http.Handle(*metricsPath, promhttp.Handler())
+	http.Handle(*metricsPath, targets.metricsHandler())
+	http.Handle("/probe", probe)
+	if *enableLifecycle {
+		http.Handle("/-/reload", targets.reloadHandler())
+	}
+	http.Handle(*chatPath, targets.targetHandler(collector.Collector.ChatHandler))
+	http.Handle(*eventsPath, targets.targetHandler(collector.Collector.EventsHandler))
+	if *enableSave {
+		http.Handle("/-/save", targets.targetHandler(collector.Collector.SaveHandler))
+	}
 	http.Handle("/", landingPage)
 
 	srv := &http.Server{}
@@ -0,0 +1,283 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+// The maximum number of admin list events retained for the events endpoint.
+const adminEventLogSize = 500
+
+// The types of admin list events.
+const (
+	adminEventBan     = "ban"
+	adminEventUnban   = "unban"
+	adminEventKick    = "kick"
+	adminEventCheater = "cheater"
+)
+
+var (
+	bannedPlayersDesc     = getSEDesc("banned_player", "count", "The number of banned players.", nil)
+	kickedPlayersDesc     = getSEDesc("kicked_player", "count", "The number of kicked players.", nil)
+	cheatersDesc          = getSEDesc("cheaters", "count", "The number of players marked as cheaters.", nil)
+	playerBansDesc        = getSEDesc("player_bans", "total", "The number of players banned since the exporter started.", nil)
+	playerUnbansDesc      = getSEDesc("player_unbans", "total", "The number of players unbanned since the exporter started.", nil)
+	playerKicksDesc       = getSEDesc("player_kicks", "total", "The number of players kicked since the exporter started.", nil)
+	cheaterDetectionsDesc = getSEDesc("cheater_detections", "total", "The number of cheaters detected since the exporter started.", nil)
+)
+
+func init() {
+	registerCollector("admin_lists", defaultEnabled, newAdminListCollector)
+}
+
+// A change of the banned, kicked or cheater lists.
+type adminEvent struct {
+	Type string `json:"type"`
+	// The time at which the exporter noticed the change.
+	Time           time.Time `json:"time"`
+	SteamID        uint64    `json:"steam_id"`
+	Name           string    `json:"name"`
+	Explanation    string    `json:"explanation,omitempty"`
+	ServerDateTime string    `json:"server_date_time,omitempty"`
+}
+
+// Tracks the banned, kicked and cheater lists between polls to count the
+// players added to and removed from them, and keeps the most recent changes
+// for the events endpoint.
+type adminLists struct {
+	mu       sync.Mutex
+	started  bool
+	banned   map[uint64]string
+	kicked   map[uint64]string
+	cheaters map[int]bool
+
+	bans     uint64
+	unbans   uint64
+	kicks    uint64
+	detected uint64
+	events   []adminEvent
+}
+
+func newAdminLists() *adminLists {
+	return &adminLists{
+		banned:   make(map[uint64]string),
+		kicked:   make(map[uint64]string),
+		cheaters: make(map[int]bool),
+	}
+}
+
+// Fetch the lists and record the changes since the last ingestion, noticed at
+// the given time. The first ingestion only records the lists.
+func (l *adminLists) ingest(ctx context.Context, client *vrage_client.VRageClient, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	banned, err := client.GetBannedPlayers(ctx)
+	if err != nil {
+		return err
+	}
+
+	kicked, err := client.GetKickedPlayers(ctx)
+	if err != nil {
+		return err
+	}
+
+	cheaters, err := client.GetCheaters(ctx)
+	if err != nil {
+		return err
+	}
+
+	record := func(event adminEvent) {
+		event.Time = now
+		l.events = append(l.events, event)
+	}
+
+	seen := make(map[uint64]string)
+	for _, player := range banned.Data.BannedPlayers {
+		seen[player.SteamID] = player.DisplayName
+		if _, ok := l.banned[player.SteamID]; !ok && l.started {
+			l.bans++
+			record(adminEvent{Type: adminEventBan, SteamID: player.SteamID, Name: player.DisplayName})
+		}
+	}
+	for steamId, name := range l.banned {
+		if _, ok := seen[steamId]; !ok {
+			l.unbans++
+			record(adminEvent{Type: adminEventUnban, SteamID: steamId, Name: name})
+		}
+	}
+	l.banned = seen
+
+	seen = make(map[uint64]string)
+	for _, player := range kicked.Data.KickedPlayers {
+		seen[player.SteamID] = player.DisplayName
+		if _, ok := l.kicked[player.SteamID]; !ok && l.started {
+			l.kicks++
+			record(adminEvent{Type: adminEventKick, SteamID: player.SteamID, Name: player.DisplayName})
+		}
+	}
+	l.kicked = seen
+
+	detected := make(map[int]bool)
+	for _, cheater := range cheaters.Data.Cheaters {
+		detected[cheater.Id] = true
+		if !l.cheaters[cheater.Id] && l.started {
+			l.detected++
+			record(adminEvent{
+				Type:           adminEventCheater,
+				SteamID:        cheater.PlayerId,
+				Name:           cheater.Name,
+				Explanation:    cheater.Explanation,
+				ServerDateTime: cheater.ServerDateTime,
+			})
+		}
+	}
+	l.cheaters = detected
+
+	if len(l.events) > adminEventLogSize {
+		l.events = append([]adminEvent(nil), l.events[len(l.events)-adminEventLogSize:]...)
+	}
+	l.started = true
+
+	return nil
+}
+
+// Returns the retained events noticed after the given time.
+func (l *adminLists) since(t time.Time) []adminEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := []adminEvent{}
+	for i := range l.events {
+		if l.events[i].Time.After(t) {
+			events = append(events, l.events[i])
+		}
+	}
+	return events
+}
+
+// Collects the sizes of the banned, kicked and cheater lists, and the number
+// of changes to these lists.
+type adminListCollector struct {
+	client *vrage_client.VRageClient
+	logger log.Logger
+	now    func() time.Time
+	lists  *adminLists
+}
+
+func newAdminListCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &adminListCollector{client: client, logger: logger, now: options.Now, lists: newAdminLists()}
+}
+
+func (c *adminListCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bannedPlayersDesc
+	ch <- kickedPlayersDesc
+	ch <- cheatersDesc
+	ch <- playerBansDesc
+	ch <- playerUnbansDesc
+	ch <- playerKicksDesc
+	ch <- cheaterDetectionsDesc
+}
+
+func (c *adminListCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	if err := c.lists.ingest(ctx, c.client, c.now()); err != nil {
+		return err
+	}
+
+	c.lists.mu.Lock()
+	defer c.lists.mu.Unlock()
+
+	// space_engineers_banned_player_count
+	ch <- prometheus.MustNewConstMetric(
+		bannedPlayersDesc,
+		prometheus.GaugeValue,
+		float64(len(c.lists.banned)),
+	)
+
+	// space_engineers_kicked_player_count
+	ch <- prometheus.MustNewConstMetric(
+		kickedPlayersDesc,
+		prometheus.GaugeValue,
+		float64(len(c.lists.kicked)),
+	)
+
+	// space_engineers_cheaters_count
+	ch <- prometheus.MustNewConstMetric(
+		cheatersDesc,
+		prometheus.GaugeValue,
+		float64(len(c.lists.cheaters)),
+	)
+
+	// space_engineers_player_bans_total
+	ch <- prometheus.MustNewConstMetric(
+		playerBansDesc,
+		prometheus.CounterValue,
+		float64(c.lists.bans),
+	)
+
+	// space_engineers_player_unbans_total
+	ch <- prometheus.MustNewConstMetric(
+		playerUnbansDesc,
+		prometheus.CounterValue,
+		float64(c.lists.unbans),
+	)
+
+	// space_engineers_player_kicks_total
+	ch <- prometheus.MustNewConstMetric(
+		playerKicksDesc,
+		prometheus.CounterValue,
+		float64(c.lists.kicks),
+	)
+
+	// space_engineers_cheater_detections_total
+	ch <- prometheus.MustNewConstMetric(
+		cheaterDetectionsDesc,
+		prometheus.CounterValue,
+		float64(c.lists.detected),
+	)
+
+	return nil
+}
+
+// Returns a handler serving the most recent changes of the banned, kicked and
+// cheater lists as JSON. The optional "since" query parameter limits the
+// response to the changes noticed after the given Unix timestamp.
+func (c Collector) EventsHandler() http.Handler {
+	return c.collectors["admin_lists"].(*adminListCollector).handler()
+}
+
+func (c *adminListCollector) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if v := r.URL.Query().Get("since"); v != "" {
+			seconds, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid since parameter", http.StatusBadRequest)
+				return
+			}
+			since = time.Unix(seconds, 0)
+		}
+
+		if err := c.lists.ingest(r.Context(), c.client, c.now()); err != nil {
+			level.Error(c.logger).Log("msg", "Failed to retrieve admin lists", "err", err)
+			http.Error(w, "failed to retrieve admin lists", http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"events": c.lists.since(since),
+		}); err != nil {
+			level.Error(c.logger).Log("msg", "Failed to encode admin list events", "err", err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var asteroidDesc = getSEDesc("asteroid", "info", "Information about the fixed asteroids.", planetLabels)
+
+func init() {
+	registerCollector("asteroids", defaultEnabled, newAsteroidCollector)
+}
+
+// Collects the fixed asteroids of the world.
+type asteroidCollector struct {
+	client *vrage_client.VRageClient
+}
+
+func newAsteroidCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &asteroidCollector{client: client}
+}
+
+func (c *asteroidCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- asteroidDesc
+}
+
+func (c *asteroidCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	resp, err := c.client.GetAsteroids(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range resp.Data.Asteroids {
+		asteroid := &resp.Data.Asteroids[i]
+		ch <- prometheus.MustNewConstMetric(
+			asteroidDesc,
+			prometheus.GaugeValue,
+			1,
+			asteroid.DisplayName,
+			fmt.Sprintf("%v", asteroid.EntityId),
+			fmt.Sprintf("%v", asteroid.Position.X),
+			fmt.Sprintf("%v", asteroid.Position.Y),
+			fmt.Sprintf("%v", asteroid.Position.Z),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	characterLabels            = []string{"entity_id", "display_name"}
+	characterCountDesc         = getSEDesc("character", "count", "The number of characters on the server.", nil)
+	characterSpeedDesc         = getSEDesc("character_speed", "meters_per_second", "The linear speed of each character.", characterLabels)
+	characterMassDesc          = getSEDesc("character_mass", "kilograms", "The mass of each character.", characterLabels)
+	orphanedCharacterCountDesc = getSEDesc("orphaned_character", "count", "The number of characters not belonging to an online player.", nil)
+)
+
+func init() {
+	registerCollector("characters", defaultEnabled, newCharacterCollector)
+}
+
+// Collects the characters and the ones not belonging to an online player.
+type characterCollector struct{}
+
+func newCharacterCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &characterCollector{}
+}
+
+func (c *characterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- characterCountDesc
+	ch <- characterSpeedDesc
+	ch <- characterMassDesc
+	ch <- orphanedCharacterCountDesc
+}
+
+func (c *characterCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	resp, err := cache.GetCharacters(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Characters are only identified by their display name, so that is what
+	// we match against the online players.
+	players, err := cache.GetPlayers(ctx)
+	if err != nil {
+		return err
+	}
+	online := make(map[string]bool)
+	for i := range players.Data.Players {
+		online[players.Data.Players[i].DisplayName] = true
+	}
+
+	// space_engineers_character_count
+	ch <- prometheus.MustNewConstMetric(
+		characterCountDesc,
+		prometheus.GaugeValue,
+		float64(len(resp.Data.Characters)),
+	)
+
+	orphaned := 0
+	for i := range resp.Data.Characters {
+		character := &resp.Data.Characters[i]
+		entityId := fmt.Sprintf("%v", character.EntityId)
+
+		if !online[character.DisplayName] {
+			orphaned++
+		}
+
+		// space_engineers_character_speed_meters_per_second
+		ch <- prometheus.MustNewConstMetric(
+			characterSpeedDesc,
+			prometheus.GaugeValue,
+			character.LinearSpeed,
+			entityId,
+			character.DisplayName,
+		)
+
+		// space_engineers_character_mass_kilograms
+		ch <- prometheus.MustNewConstMetric(
+			characterMassDesc,
+			prometheus.GaugeValue,
+			character.Mass,
+			entityId,
+			character.DisplayName,
+		)
+	}
+
+	// space_engineers_orphaned_character_count
+	ch <- prometheus.MustNewConstMetric(
+		orphanedCharacterCountDesc,
+		prometheus.GaugeValue,
+		float64(orphaned),
+	)
+
+	return nil
+}
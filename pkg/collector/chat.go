@@ -0,0 +1,154 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+// The maximum number of chat messages retained for the chat endpoint.
+const chatLogSize = 500
+
+var chatMessagesDesc = getSEDesc("chat_messages", "total", "The number of chat messages sent by each player.", []string{"player"})
+
+func init() {
+	registerCollector("chat", defaultEnabled, newChatCollector)
+}
+
+// Keeps track of the chat messages seen so far so that the message counts
+// can be exported as counters and the most recent messages can be served to
+// other consumers.
+type chatLog struct {
+	mu            sync.Mutex
+	lastTimestamp int64
+	counts        map[string]uint64
+	messages      []vrage_client.ChatMessageResponseData
+}
+
+func newChatLog() *chatLog {
+	return &chatLog{counts: make(map[string]uint64)}
+}
+
+// Fetch the messages sent since the last ingestion and record them.
+func (l *chatLog) ingest(ctx context.Context, client *vrage_client.VRageClient) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	resp, err := client.GetChatMessages(ctx, l.lastTimestamp)
+	if err != nil {
+		return err
+	}
+
+	// Several messages may share a timestamp, so the messages are compared
+	// with the timestamp of the previous ingestion rather than with the
+	// previous message.
+	latest := l.lastTimestamp
+	for i := range resp.Data.Messages {
+		msg := resp.Data.Messages[i]
+		if msg.Timestamp <= l.lastTimestamp {
+			continue
+		}
+		if msg.Timestamp > latest {
+			latest = msg.Timestamp
+		}
+		l.counts[msg.DisplayName]++
+		l.messages = append(l.messages, msg)
+	}
+	l.lastTimestamp = latest
+
+	if len(l.messages) > chatLogSize {
+		l.messages = append([]vrage_client.ChatMessageResponseData(nil), l.messages[len(l.messages)-chatLogSize:]...)
+	}
+
+	return nil
+}
+
+// Returns the retained messages sent after the given timestamp.
+func (l *chatLog) since(timestamp int64) []vrage_client.ChatMessageResponseData {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	messages := []vrage_client.ChatMessageResponseData{}
+	for i := range l.messages {
+		if l.messages[i].Timestamp > timestamp {
+			messages = append(messages, l.messages[i])
+		}
+	}
+	return messages
+}
+
+// Collects the number of chat messages sent by each player.
+type chatCollector struct {
+	client *vrage_client.VRageClient
+	logger log.Logger
+	chat   *chatLog
+}
+
+func newChatCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &chatCollector{client: client, logger: logger, chat: newChatLog()}
+}
+
+func (c *chatCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- chatMessagesDesc
+}
+
+func (c *chatCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	if err := c.chat.ingest(ctx, c.client); err != nil {
+		return err
+	}
+
+	c.chat.mu.Lock()
+	defer c.chat.mu.Unlock()
+
+	for player, count := range c.chat.counts {
+		// space_engineers_chat_messages_total
+		ch <- prometheus.MustNewConstMetric(
+			chatMessagesDesc,
+			prometheus.CounterValue,
+			float64(count),
+			player,
+		)
+	}
+
+	return nil
+}
+
+// Returns a handler serving the most recent chat messages as JSON. The
+// optional "since" query parameter limits the response to the messages sent
+// after the given timestamp.
+func (c Collector) ChatHandler() http.Handler {
+	return c.collectors["chat"].(*chatCollector).handler()
+}
+
+func (c *chatCollector) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var since int64
+		if v := r.URL.Query().Get("since"); v != "" {
+			var err error
+			if since, err = strconv.ParseInt(v, 10, 64); err != nil {
+				http.Error(w, "invalid since parameter", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := c.chat.ingest(r.Context(), c.client); err != nil {
+			level.Error(c.logger).Log("msg", "Failed to retrieve chat messages", "err", err)
+			http.Error(w, "failed to retrieve chat messages", http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"messages": c.chat.since(since),
+		}); err != nil {
+			level.Error(c.logger).Log("msg", "Failed to encode chat messages", "err", err)
+		}
+	})
+}
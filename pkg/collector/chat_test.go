@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+	"github.com/thelande/space-engineers-exporter/pkg/vrage_client/vragetest"
+)
+
+func TestChatLogSameTimestamp(t *testing.T) {
+	srv := vragetest.NewServer()
+	defer srv.Close()
+	client, err := srv.NewClient(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.MustSetData("GET", "/v1/session/chat", map[string]interface{}{
+		"Messages": []vrage_client.ChatMessageResponseData{
+			{DisplayName: "A", Timestamp: 100},
+			{DisplayName: "B", Timestamp: 100},
+			{DisplayName: "A", Timestamp: 100},
+		},
+	})
+
+	chat := newChatLog()
+	// The second ingestion receives the same messages, which must not be
+	// counted twice.
+	for i := 0; i < 2; i++ {
+		if err := chat.ingest(context.Background(), client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if chat.counts["A"] != 2 || chat.counts["B"] != 1 {
+		t.Errorf("unexpected counts %v", chat.counts)
+	}
+	if n := len(chat.since(0)); n != 3 {
+		t.Errorf("expected 3 messages, got %d", n)
+	}
+}
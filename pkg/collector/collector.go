@@ -1,7 +1,12 @@
 package collector
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -9,325 +14,312 @@ import (
 	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
 )
 
-const namespace = "space_engineers"
+const (
+	namespace = "space_engineers"
+
+	defaultEnabled  = true
+	defaultDisabled = false
+)
 
 func getSEDesc(name string, unit string, help string, labels []string) *prometheus.Desc {
+	for _, label := range labels {
+		labelNames[label] = true
+	}
 	return prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, name, unit), help, labels, nil,
 	)
 }
 
 var (
-	serverInfoLabels = []string{"server_name", "world_name", "version", "server_id"}
-
-	upDesc                = getSEDesc("", "up", "Did the remote API respond to the queries.", nil)
-	serverInfoDesc        = getSEDesc("", "info", "Information about the server", serverInfoLabels)
-	readyDesc             = getSEDesc("", "ready", "Is the server ready?", nil)
-	upTimeDesc            = getSEDesc("up", "seconds", "The number of seconds that the server has been up.", nil)
-	playerCountDesc       = getSEDesc("player", "count", "The number of players currently connected to the server.", nil)
-	simulationCpuLoadDesc = getSEDesc("simulation_cpu_load", "percent", "The simulation thread CPU load.", nil)
-	simulationSpeedDesc   = getSEDesc("", "simulation_speed", "The simulation speed factor.", nil)
-	pcuUsedDesc           = getSEDesc("pcu_used", "total", "The total number of PCU used.", nil)
-	piratePcuUsedDesc     = getSEDesc("pirate_pcu_used", "total", "The total number of PCU used by pirate factions.", nil)
-
-	planetLabels = []string{"display_name", "entity_id", "x", "y", "z"}
-	planetDesc   = getSEDesc("planet", "info", "Information about the planets.", planetLabels)
-	asteroidDesc = getSEDesc("asteroid", "info", "Information about the fixed asteroids.", planetLabels)
-
-	gridLabels    = []string{"powered", "grid_size"}
-	pcuLabels     = []string{"powered", "grid_size", "owner"}
-	gridCountDesc = getSEDesc("grid", "count", "The number of grids on the server.", gridLabels)
-	pcuCountDesc  = getSEDesc("pcu", "count", "The number of PCUs used on the server.", pcuLabels)
-
-	bannedPlayersDesc = getSEDesc("banned_player", "count", "The number of banned players.", nil)
-	kickedPlayersDesc = getSEDesc("kicked_player", "count", "The number of kicked players.", nil)
-	cheatersDesc      = getSEDesc("cheaters", "count", "The number of players marked as cheaters.", nil)
+	scrapeDurationDesc = getSEDesc("scrape_collector_duration", "seconds", "Duration of a collector scrape.", []string{"collector"})
+	scrapeSuccessDesc  = getSEDesc("scrape_collector", "success", "Whether a collector succeeded.", []string{"collector"})
+
+	upDesc = getSEDesc("", "up", "Did the remote API respond to the queries.", nil)
+
+	circuitStateDesc = getSEDesc("remote_api_circuit", "state", "The state of the circuit breaker of the remote API client: 0 when closed, 1 when half-open and 2 when open.", nil)
+
+	factories      = make(map[string]factoryFunc)
+	enabledDefault = make(map[string]bool)
+
+	// The names of the variable labels of the metrics.
+	labelNames = make(map[string]bool)
 )
 
-type Collector struct {
-	client *vrage_client.VRageClient
-	logger log.Logger
+// A collector for a part of the remote API.
+type subCollector interface {
+	Describe(ch chan<- *prometheus.Desc)
+	// Query the remote API and send the resulting metrics. The responses
+	// used by several sub-collectors are queried through the given cache.
+	Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error
 }
 
-func NewCollector(client *vrage_client.VRageClient, logger log.Logger) Collector {
-	return Collector{client: client, logger: logger}
-}
+// Creates a sub-collector querying the given client.
+type factoryFunc func(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector
 
-func (c Collector) Describe(ch chan<- *prometheus.Desc) {
-	metrics := []*prometheus.Desc{
-		upDesc,
-		serverInfoDesc,
-		readyDesc,
-		upTimeDesc,
-		playerCountDesc,
-		simulationCpuLoadDesc,
-		simulationSpeedDesc,
-		pcuUsedDesc,
-		piratePcuUsedDesc,
-		planetDesc,
-		asteroidDesc,
-		gridCountDesc,
-		pcuCountDesc,
-		bannedPlayersDesc,
-		kickedPlayersDesc,
-		cheatersDesc,
-	}
-	for i := range metrics {
-		ch <- metrics[i]
-	}
+// Make a sub-collector available under the given name. Called from the init
+// function of the file implementing the sub-collector.
+func registerCollector(name string, isDefaultEnabled bool, factory factoryFunc) {
+	factories[name] = factory
+	enabledDefault[name] = isDefaultEnabled
 }
 
-func (c Collector) SetUp(ch chan<- prometheus.Metric, up bool) {
-	upVal := 0.0
-	if up {
-		upVal = 1
+// Returns the sorted names of the available sub-collectors.
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
 	}
-	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, upVal)
+	sort.Strings(names)
+	return names
 }
 
-func (c Collector) CollectServerInfo(ch chan<- prometheus.Metric) error {
-	serverInfo, err := c.client.GetServerDetails()
-	if err != nil {
-		return err
-	}
-
-	// space_engineers_info
-	ch <- prometheus.MustNewConstMetric(
-		serverInfoDesc,
-		prometheus.GaugeValue,
-		1,
-		serverInfo.Data.ServerName,
-		serverInfo.Data.WorldName,
-		serverInfo.Data.Version,
-		fmt.Sprintf("%v", serverInfo.Data.ServerId),
-	)
-
-	// space_engineers_ready
-	var readyVal float64
-	if serverInfo.Data.IsReady {
-		readyVal = 1
+// Returns the sorted names of the labels that vary between the metrics of the
+// sub-collectors, which cannot be attached to every metric of a server.
+func LabelNames() []string {
+	names := make([]string, 0, len(labelNames))
+	for name := range labelNames {
+		names = append(names, name)
 	}
-	ch <- prometheus.MustNewConstMetric(
-		readyDesc,
-		prometheus.GaugeValue,
-		readyVal,
-	)
-
-	// space_engineers_up_seconds
-	ch <- prometheus.MustNewConstMetric(
-		upTimeDesc,
-		prometheus.CounterValue,
-		float64(serverInfo.Data.TotalTime),
-	)
+	sort.Strings(names)
+	return names
+}
 
-	// space_engineers_player_count
-	ch <- prometheus.MustNewConstMetric(
-		playerCountDesc,
-		prometheus.GaugeValue,
-		float64(serverInfo.Data.Players),
-	)
+// Returns true if the sub-collector with the given name is enabled by default.
+func DefaultEnabled(name string) bool {
+	return enabledDefault[name]
+}
 
-	// space_engineers_simulation_cpu_load_percent
-	ch <- prometheus.MustNewConstMetric(
-		simulationCpuLoadDesc,
-		prometheus.GaugeValue,
-		serverInfo.Data.SimulationCpuLoad,
-	)
+// Options controlling the behaviour of a Collector.
+type Options struct {
+	// The maximum number of sub-collectors querying the remote API at the
+	// same time. Values below 1 are treated as 1.
+	MaxConcurrency int
 
-	// space_engineers_simulation_speed
-	ch <- prometheus.MustNewConstMetric(
-		simulationSpeedDesc,
-		prometheus.GaugeValue,
-		serverInfo.Data.SimSpeed,
-	)
+	// The names of the sub-collectors to run. The sub-collectors enabled by
+	// default are run when nil, and unknown names are ignored.
+	Collectors []string
 
-	// space_engineers_pcu_used_total
-	ch <- prometheus.MustNewConstMetric(
-		pcuUsedDesc,
-		prometheus.GaugeValue,
-		float64(serverInfo.Data.UsedPCU),
-	)
+	// Returns the time of the polls compared with the previous ones.
+	// time.Now is used when nil.
+	Now func() time.Time
 
-	// space_engineers_pirate_pcu_used_total
-	ch <- prometheus.MustNewConstMetric(
-		piratePcuUsedDesc,
-		prometheus.GaugeValue,
-		float64(serverInfo.Data.PirateUsedPCU),
-	)
+	Grids    GridOptions
+	Players  PlayerOptions
+	Factions FactionOptions
+	Sectors  SectorOptions
+}
 
-	return nil
+type Collector struct {
+	ctx        context.Context
+	client     *vrage_client.VRageClient
+	logger     log.Logger
+	options    Options
+	collectors map[string]subCollector
+	enabled    []string
+	saves      *saveTracker
 }
 
-func (c Collector) CollectPlanets(ch chan<- prometheus.Metric) error {
-	resp, err := c.client.GetPlanets()
-	if err != nil {
-		return err
+func NewCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) Collector {
+	if options.MaxConcurrency < 1 {
+		options.MaxConcurrency = 1
+	}
+	if options.Now == nil {
+		options.Now = time.Now
 	}
 
-	for i := range resp.Data.Planets {
-		planet := &resp.Data.Planets[i]
-		ch <- prometheus.MustNewConstMetric(
-			planetDesc,
-			prometheus.GaugeValue,
-			1,
-			planet.DisplayName,
-			fmt.Sprintf("%v", planet.EntityId),
-			fmt.Sprintf("%v", planet.Position.X),
-			fmt.Sprintf("%v", planet.Position.Y),
-			fmt.Sprintf("%v", planet.Position.Z),
-		)
+	// Every sub-collector is created, so that the ones keeping state can
+	// serve their other endpoints even when their metrics are disabled.
+	collectors := make(map[string]subCollector)
+	for name, factory := range factories {
+		collectors[name] = factory(client, log.With(logger, "collector", name), options)
 	}
 
-	return nil
-}
+	selected := enabledDefault
+	if options.Collectors != nil {
+		selected = make(map[string]bool)
+		for _, name := range options.Collectors {
+			selected[name] = true
+		}
+	}
 
-func (c Collector) CollectAsteroids(ch chan<- prometheus.Metric) error {
-	resp, err := c.client.GetAsteroids()
-	if err != nil {
-		return err
+	var enabled []string
+	for _, name := range Names() {
+		if selected[name] {
+			enabled = append(enabled, name)
+		}
 	}
 
-	for i := range resp.Data.Asteroids {
-		asteroid := &resp.Data.Asteroids[i]
-		ch <- prometheus.MustNewConstMetric(
-			asteroidDesc,
-			prometheus.GaugeValue,
-			1,
-			asteroid.DisplayName,
-			fmt.Sprintf("%v", asteroid.EntityId),
-			fmt.Sprintf("%v", asteroid.Position.X),
-			fmt.Sprintf("%v", asteroid.Position.Y),
-			fmt.Sprintf("%v", asteroid.Position.Z),
-		)
+	return Collector{
+		ctx:        context.Background(),
+		client:     client,
+		logger:     logger,
+		options:    options,
+		collectors: collectors,
+		enabled:    enabled,
+		saves:      &saveTracker{},
 	}
+}
 
-	return nil
+// Returns a copy of the collector that bounds the remote API queries made
+// during collection by the given context. The copy shares its state with the
+// original collector.
+func (c Collector) WithContext(ctx context.Context) Collector {
+	c.ctx = ctx
+	return c
 }
 
-func (c Collector) CollectGrids(ch chan<- prometheus.Metric) error {
-	resp, err := c.client.GetGrids()
-	if err != nil {
-		return err
+// Returns a copy of the collector that only runs the given sub-collectors.
+// The copy shares its state with the original collector. Returns an error if
+// one of the sub-collectors is unknown or disabled.
+func (c Collector) Filter(names []string) (Collector, error) {
+	enabled := make(map[string]bool)
+	for _, name := range c.enabled {
+		enabled[name] = true
 	}
 
-	owners := make(map[string]bool)
-	for i := range resp.Data.Grids {
-		grid := &resp.Data.Grids[i]
-		if ok := owners[grid.OwnerDisplayName]; !ok {
-			owners[grid.OwnerDisplayName] = true
+	selected := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := factories[name]; !ok {
+			return c, fmt.Errorf("unknown collector %q, must be one of %s", name, strings.Join(Names(), ", "))
+		}
+		if !enabled[name] {
+			return c, fmt.Errorf("collector %q is disabled", name)
 		}
+		selected[name] = true
 	}
 
-	for _, powered := range []bool{true, false} {
-		for _, size := range []string{"Large", "Small"} {
-			count := 0
-			for i := range resp.Data.Grids {
-				grid := &resp.Data.Grids[i]
-				if grid.IsPowered == powered && grid.GridSize == size {
-					count++
-				}
-			}
-			ch <- prometheus.MustNewConstMetric(
-				gridCountDesc,
-				prometheus.GaugeValue,
-				float64(count),
-				fmt.Sprintf("%v", powered),
-				size,
-			)
-
-			for owner := range owners {
-				count = 0
-				for i := range resp.Data.Grids {
-					grid := &resp.Data.Grids[i]
-					if grid.IsPowered == powered && grid.GridSize == size && grid.OwnerDisplayName == owner {
-						count += int(grid.PCU)
-					}
-				}
-				ch <- prometheus.MustNewConstMetric(
-					pcuCountDesc,
-					prometheus.GaugeValue,
-					float64(count),
-					fmt.Sprintf("%v", powered),
-					size,
-					owner,
-				)
-			}
+	c.enabled = nil
+	for _, name := range Names() {
+		if selected[name] {
+			c.enabled = append(c.enabled, name)
 		}
 	}
-
-	return nil
+	return c, nil
 }
 
-func (c Collector) CollectPlayers(ch chan<- prometheus.Metric) error {
-	banned, err := c.client.GetBannedPlayers()
-	if err != nil {
-		return err
+func (c Collector) Describe(ch chan<- *prometheus.Desc) {
+	metrics := []*prometheus.Desc{
+		scrapeDurationDesc,
+		scrapeSuccessDesc,
+		upDesc,
+		circuitStateDesc,
+		lastSaveTimestampDesc,
+		lastSaveDurationDesc,
+		lastSaveSuccessDesc,
 	}
-	ch <- prometheus.MustNewConstMetric(
-		bannedPlayersDesc,
-		prometheus.GaugeValue,
-		float64(len(banned.Data.BannedPlayers)),
-	)
-
-	kicked, err := c.client.GetKickedPlayers()
-	if err != nil {
-		return err
+	for i := range metrics {
+		ch <- metrics[i]
 	}
-	ch <- prometheus.MustNewConstMetric(
-		kickedPlayersDesc,
-		prometheus.GaugeValue,
-		float64(len(kicked.Data.KickedPlayers)),
-	)
 
-	cheaters, err := c.client.GetCheaters()
-	if err != nil {
-		return err
+	for _, name := range c.enabled {
+		c.collectors[name].Describe(ch)
 	}
-	ch <- prometheus.MustNewConstMetric(
-		cheatersDesc,
-		prometheus.GaugeValue,
-		float64(len(cheaters.Data.Cheaters)),
-	)
+}
 
-	return nil
+func (c Collector) SetUp(ch chan<- prometheus.Metric, up bool) {
+	upVal := 0.0
+	if up {
+		upVal = 1
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, upVal)
 }
 
 func (c Collector) Collect(ch chan<- prometheus.Metric) {
-	ping, err := c.client.Ping()
+	ctx := c.ctx
+
+	ping, err := c.client.Ping(ctx)
 	if err != nil {
 		level.Error(c.logger).Log("msg", "Failed to ping remote API", "err", err)
 		ping = false
 	}
 
 	c.SetUp(ch, ping)
+	ch <- prometheus.MustNewConstMetric(
+		circuitStateDesc,
+		prometheus.GaugeValue,
+		float64(c.client.CircuitState()),
+	)
+	c.CollectSaves(ch)
 
 	// Bail out now if the API is not up.
 	if !ping {
 		return
 	}
 
-	if err = c.CollectServerInfo(ch); err != nil {
-		level.Error(c.logger).Log("msg", "Failed to collect server info", "err", err)
-		return
+	// Run the sub-collectors concurrently, but buffer their metrics so that they
+	// are always emitted in the same order.
+	cache := newResponseCache(c.client)
+	results := make([][]prometheus.Metric, len(c.enabled))
+	sem := make(chan struct{}, c.options.MaxConcurrency)
+	var wg sync.WaitGroup
+	for i := range c.enabled {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = bufferMetrics(func(ch chan<- prometheus.Metric) {
+				c.execute(ctx, cache, ch, c.enabled[i])
+			})
+		}(i)
 	}
+	wg.Wait()
 
-	if err = c.CollectPlanets(ch); err != nil {
-		level.Error(c.logger).Log("msg", "Failed to collect planet info", "err", err)
-		return
+	for i := range results {
+		for _, metric := range results[i] {
+			ch <- metric
+		}
 	}
+}
 
-	if err = c.CollectAsteroids(ch); err != nil {
-		level.Error(c.logger).Log("msg", "Failed to collect asteroid info", "err", err)
-		return
-	}
+// Run the given collection function and return the metrics it produced.
+func bufferMetrics(collect func(chan<- prometheus.Metric)) []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	done := make(chan []prometheus.Metric)
+	go func() {
+		var metrics []prometheus.Metric
+		for metric := range ch {
+			metrics = append(metrics, metric)
+		}
+		done <- metrics
+	}()
 
-	if err = c.CollectGrids(ch); err != nil {
-		level.Error(c.logger).Log("msg", "Failed to collect grid info", "err", err)
-		return
+	collect(ch)
+	close(ch)
+	return <-done
+}
+
+// Run a sub-collector and report its outcome. A failing sub-collector does
+// not prevent the other ones from running.
+func (c Collector) execute(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric, name string) {
+	var err error
+	begin := time.Now()
+
+	// The remaining queries would be cancelled anyway once the scrape
+	// deadline has passed, so don't bother starting them.
+	if err = ctx.Err(); err != nil {
+		level.Warn(c.logger).Log("msg", "Scrape deadline exceeded, skipping collector", "collector", name, "err", err)
+	} else if err = c.collectors[name].Update(ctx, cache, ch); err != nil {
+		level.Error(c.logger).Log("msg", "Collector failed", "collector", name, "err", err)
 	}
+	duration := time.Since(begin)
 
-	if err = c.CollectPlayers(ch); err != nil {
-		level.Error(c.logger).Log("msg", "Failed to collect banned player count", "err", err)
-		return
+	successVal := 0.0
+	if err == nil {
+		successVal = 1
+		level.Debug(c.logger).Log("msg", "Collector succeeded", "collector", name, "duration_seconds", duration.Seconds())
 	}
+
+	ch <- prometheus.MustNewConstMetric(
+		scrapeDurationDesc,
+		prometheus.GaugeValue,
+		duration.Seconds(),
+		name,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		scrapeSuccessDesc,
+		prometheus.GaugeValue,
+		successVal,
+		name,
+	)
 }
@@ -0,0 +1,252 @@
+package collector
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+	"github.com/thelande/space-engineers-exporter/pkg/vrage_client/vragetest"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// Metrics whose value depends on the time of the test, which are left out of
+// the golden files.
+var volatileMetrics = map[string]bool{
+	"space_engineers_scrape_collector_duration_seconds": true,
+}
+
+// The scenarios in testdata, with the number of polls of the remote API before
+// the metrics are compared with the golden file. The scenarios with several
+// polls hold a fixture per poll for the routes whose response changes.
+var scenarios = []struct {
+	name  string
+	polls int
+}{
+	{"world", 1},
+	{"down", 1},
+	{"changes", 3},
+}
+
+// The time of the first poll of the test collectors, and the time between
+// their polls.
+var (
+	testPollStart    = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	testPollInterval = time.Minute
+)
+
+// Returns a collector of every sub-collector, querying a fake remote API
+// serving the fixtures of the given scenario in testdata. The collector has
+// already polled the remote API polls-1 times, a minute apart, and its clock
+// is set to the time of the next poll.
+func newTestCollector(t *testing.T, scenario string, polls int) Collector {
+	t.Helper()
+
+	fixtures, err := vrage_client.LoadFixtures(filepath.Join("testdata", scenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := vragetest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.Replay(fixtures)
+
+	client, err := srv.NewClient(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := testPollStart
+	c := NewCollector(client, log.NewNopLogger(), Options{
+		Collectors: Names(),
+		Now:        func() time.Time { return now },
+		Grids: GridOptions{
+			TopN:   3,
+			RankBy: GridRankByPCU,
+		},
+		Factions: FactionOptions{
+			PCUBudget:  20000,
+			PCUBudgets: map[string]uint{"BLD": 10000},
+		},
+		Sectors: SectorOptions{
+			SizeKm:            100,
+			PlanetDistancesKm: []float64{60, 120},
+		},
+	})
+
+	for i := 1; i < polls; i++ {
+		testutil.CollectAndCount(c)
+		now = now.Add(testPollInterval)
+	}
+	return c
+}
+
+// Collect the metrics of the collector, leaving out the volatile ones.
+func gatherStable(t *testing.T, c prometheus.Collector) []*dto.MetricFamily {
+	t.Helper()
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(c)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stable []*dto.MetricFamily
+	for _, family := range families {
+		if !volatileMetrics[family.GetName()] {
+			stable = append(stable, family)
+		}
+	}
+	return stable
+}
+
+// Write the given metrics to the given golden file.
+func writeGolden(t *testing.T, families []*dto.MetricFamily, path string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Returns the sorted names of the metrics in the given golden file.
+func goldenMetricNames(t *testing.T, path string) []string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Compare the metrics of a collector querying the fixtures of each scenario in
+// testdata with the golden file of the scenario. The golden files are
+// regenerated with the -update flag.
+func TestCollectorGolden(t *testing.T) {
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			golden := filepath.Join("testdata", scenario.name+".prom")
+			if *update {
+				writeGolden(t, gatherStable(t, newTestCollector(t, scenario.name, scenario.polls)), golden)
+			}
+			names := goldenMetricNames(t, golden)
+
+			// CollectAndCompare ignores the metrics missing from the
+			// golden file, so compare the metric names first.
+			var collected []string
+			for _, family := range gatherStable(t, newTestCollector(t, scenario.name, scenario.polls)) {
+				collected = append(collected, family.GetName())
+			}
+			if strings.Join(collected, ",") != strings.Join(names, ",") {
+				t.Errorf("collected metrics %v, golden file has %v", collected, names)
+			}
+
+			f, err := os.Open(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if err := testutil.CollectAndCompare(newTestCollector(t, scenario.name, scenario.polls), f, names...); err != nil {
+				t.Errorf("%v\nRun go test ./pkg/collector -update to update the golden files.", err)
+			}
+		})
+	}
+}
+
+// The endpoints used by several sub-collectors are queried once per scrape.
+func TestCollectorSharesResponses(t *testing.T) {
+	srv := vragetest.NewServer()
+	defer srv.Close()
+	client, err := srv.NewClient(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollector(client, log.NewNopLogger(), Options{
+		Collectors:     Names(),
+		MaxConcurrency: 4,
+		Sectors:        SectorOptions{PlanetDistancesKm: []float64{60}},
+	})
+	testutil.CollectAndCount(c)
+
+	for _, path := range []string{"/v1/session/grids", "/v1/session/players", "/v1/session/characters", "/v1/session/planets"} {
+		if n := srv.Count("GET", path); n != 1 {
+			t.Errorf("expected 1 request to %s, got %d", path, n)
+		}
+	}
+}
+
+// The planets are queried for the sectors collector alone when planet
+// distances are configured, and only then.
+func TestSectorsPlanets(t *testing.T) {
+	for _, tc := range []struct {
+		distances []float64
+		requests  int
+		metrics   int
+	}{
+		{distances: []float64{60}, requests: 1, metrics: 1},
+		{distances: nil, requests: 0, metrics: 0},
+	} {
+		srv := vragetest.NewServer()
+		defer srv.Close()
+		srv.MustSetData("GET", "/v1/session/planets", map[string]interface{}{
+			"Planets": []vrage_client.PlanetResponseData{{DisplayName: "EarthLike", EntityId: 1}},
+		})
+		client, err := srv.NewClient(log.NewNopLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := NewCollector(client, log.NewNopLogger(), Options{
+			Collectors: []string{"sectors"},
+			Sectors:    SectorOptions{PlanetDistancesKm: tc.distances},
+		})
+		n := testutil.CollectAndCount(c, "space_engineers_planet_proximity_grid_count")
+
+		if r := srv.Count("GET", "/v1/session/planets"); r != tc.requests {
+			t.Errorf("distances %v: expected %d requests to the planets, got %d", tc.distances, tc.requests, r)
+		}
+		if n != tc.metrics {
+			t.Errorf("distances %v: expected %d planet proximity metrics, got %d", tc.distances, tc.metrics, n)
+		}
+	}
+}
+
+// A sector size that is not positive falls back to the default size.
+func TestSectorSizeDefault(t *testing.T) {
+	c := newSectorCollector(nil, log.NewNopLogger(), Options{Sectors: SectorOptions{SizeKm: -1}}).(*sectorCollector)
+	if s := c.sectorOf(vrage_client.EntityPosition{X: 150000, Y: -1, Z: 0}); s != (sector{1, -1, 0}) {
+		t.Errorf("unexpected sector %+v", s)
+	}
+}
@@ -0,0 +1,227 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	factionLabels             = []string{"faction_tag"}
+	factionGridCountDesc      = getSEDesc("faction_grid", "count", "The number of grids owned by the members of each faction.", factionLabels)
+	factionBlockCountDesc     = getSEDesc("faction_block", "count", "The number of blocks of the grids owned by the members of each faction.", factionLabels)
+	factionMassDesc           = getSEDesc("faction_mass", "kilograms", "The mass of the grids owned by the members of each faction.", factionLabels)
+	factionPcuDesc            = getSEDesc("faction", "pcu", "The number of PCUs used by the grids owned by the members of each faction.", factionLabels)
+	factionMembersDesc        = getSEDesc("faction_member", "count", "The number of known members of each faction.", factionLabels)
+	factionOnlineMembersDesc  = getSEDesc("faction_online_member", "count", "The number of members of each faction connected to the server.", factionLabels)
+	factionPcuBudgetDesc      = getSEDesc("faction_pcu", "budget", "The number of PCUs each faction is allowed to use.", factionLabels)
+	factionPcuUtilisationDesc = getSEDesc("faction_pcu_utilisation", "ratio", "The fraction of its PCU budget used by each faction.", factionLabels)
+)
+
+func init() {
+	registerCollector("factions", defaultEnabled, newFactionCollector)
+}
+
+// Options of the factions collector.
+type FactionOptions struct {
+	// The number of PCUs each faction is allowed to use. No budget is
+	// exported when 0.
+	PCUBudget uint
+	// Budgets of specific factions, by tag, overriding PCUBudget.
+	PCUBudgets map[string]uint
+}
+
+// Returns the PCU budget of the faction with the given tag, or 0 if it has
+// none.
+func (o FactionOptions) budget(tag string) uint {
+	if budget, ok := o.PCUBudgets[tag]; ok {
+		return budget
+	}
+	return o.PCUBudget
+}
+
+// The grids owned by the members of a faction.
+type factionTotals struct {
+	grids  uint
+	blocks uint
+	mass   float64
+	pcu    uint
+}
+
+// The faction tag of the grids whose owner was never seen online, and whose
+// faction is therefore unknown. Faction tags are at most four characters long,
+// so it cannot be the tag of an actual faction.
+const unknownFactionTag = "unknown"
+
+// Aggregates the grids by the faction of their owner. The remote API only
+// reports the faction of the players currently connected, so the faction of
+// each player is remembered once seen, for as long as the player is online or
+// owns grids. The grids of players that are not in a faction, and the grids
+// without an owner, are reported with an empty faction tag, and the grids of
+// players never seen online with unknownFactionTag.
+type factionCollector struct {
+	options FactionOptions
+
+	mu      sync.Mutex
+	members map[uint64]string
+}
+
+func newFactionCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &factionCollector{
+		options: options.Factions,
+		members: make(map[uint64]string),
+	}
+}
+
+func (c *factionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- factionGridCountDesc
+	ch <- factionBlockCountDesc
+	ch <- factionMassDesc
+	ch <- factionPcuDesc
+	ch <- factionMembersDesc
+	ch <- factionOnlineMembersDesc
+	ch <- factionPcuBudgetDesc
+	ch <- factionPcuUtilisationDesc
+}
+
+func (c *factionCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	players, err := cache.GetPlayers(ctx)
+	if err != nil {
+		return err
+	}
+
+	grids, err := cache.GetGrids(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	online := make(map[string]int)
+	seen := make(map[uint64]bool)
+	for i := range players.Data.Players {
+		player := &players.Data.Players[i]
+		c.members[player.SteamID] = player.FactionTag
+		online[player.FactionTag]++
+		seen[player.SteamID] = true
+	}
+	for i := range grids.Data.Grids {
+		seen[grids.Data.Grids[i].OwnerSteamId] = true
+	}
+	// The players that left and own no grid are forgotten, so that the
+	// members do not grow without bound.
+	for steamId := range c.members {
+		if !seen[steamId] {
+			delete(c.members, steamId)
+		}
+	}
+
+	members := make(map[string]int)
+	for _, tag := range c.members {
+		members[tag]++
+	}
+
+	totals := make(map[string]*factionTotals)
+	for tag := range members {
+		totals[tag] = &factionTotals{}
+	}
+	for i := range grids.Data.Grids {
+		grid := &grids.Data.Grids[i]
+		tag, ok := c.members[grid.OwnerSteamId]
+		if !ok && grid.OwnerSteamId != 0 {
+			tag = unknownFactionTag
+		}
+		t, ok := totals[tag]
+		if !ok {
+			t = &factionTotals{}
+			totals[tag] = t
+		}
+		t.grids++
+		t.blocks += grid.BlocksCount
+		t.mass += grid.Mass
+		t.pcu += grid.PCU
+	}
+
+	for tag, t := range totals {
+		// space_engineers_faction_grid_count
+		ch <- prometheus.MustNewConstMetric(
+			factionGridCountDesc,
+			prometheus.GaugeValue,
+			float64(t.grids),
+			tag,
+		)
+
+		// space_engineers_faction_block_count
+		ch <- prometheus.MustNewConstMetric(
+			factionBlockCountDesc,
+			prometheus.GaugeValue,
+			float64(t.blocks),
+			tag,
+		)
+
+		// space_engineers_faction_mass_kilograms
+		ch <- prometheus.MustNewConstMetric(
+			factionMassDesc,
+			prometheus.GaugeValue,
+			t.mass,
+			tag,
+		)
+
+		// space_engineers_faction_pcu
+		ch <- prometheus.MustNewConstMetric(
+			factionPcuDesc,
+			prometheus.GaugeValue,
+			float64(t.pcu),
+			tag,
+		)
+
+		// The owners of the grids without a known faction are unknown.
+		if tag == unknownFactionTag {
+			continue
+		}
+
+		// space_engineers_faction_member_count
+		ch <- prometheus.MustNewConstMetric(
+			factionMembersDesc,
+			prometheus.GaugeValue,
+			float64(members[tag]),
+			tag,
+		)
+
+		// space_engineers_faction_online_member_count
+		ch <- prometheus.MustNewConstMetric(
+			factionOnlineMembersDesc,
+			prometheus.GaugeValue,
+			float64(online[tag]),
+			tag,
+		)
+
+		// The players that are not in a faction share no budget.
+		budget := c.options.budget(tag)
+		if tag == "" || budget == 0 {
+			continue
+		}
+
+		// space_engineers_faction_pcu_budget
+		ch <- prometheus.MustNewConstMetric(
+			factionPcuBudgetDesc,
+			prometheus.GaugeValue,
+			float64(budget),
+			tag,
+		)
+
+		// space_engineers_faction_pcu_utilisation_ratio
+		ch <- prometheus.MustNewConstMetric(
+			factionPcuUtilisationDesc,
+			prometheus.GaugeValue,
+			float64(t.pcu)/float64(budget),
+			tag,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+	"github.com/thelande/space-engineers-exporter/pkg/vrage_client/vragetest"
+)
+
+func TestFactionUnknownOwners(t *testing.T) {
+	srv := vragetest.NewServer()
+	defer srv.Close()
+	client, err := srv.NewClient(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.MustSetData("GET", "/v1/session/players", map[string]interface{}{
+		"Players": []vrage_client.OnlinePlayerResponseData{
+			{SteamID: 1, DisplayName: "A", FactionTag: "BLD"},
+			{SteamID: 2, DisplayName: "B"},
+		},
+	})
+	srv.MustSetData("GET", "/v1/session/grids", map[string]interface{}{
+		"Grids": []vrage_client.GridResponseData{
+			{EntityId: 10, OwnerSteamId: 1},
+			{EntityId: 11, OwnerSteamId: 2},
+			{EntityId: 12, OwnerSteamId: 0},
+			{EntityId: 13, OwnerSteamId: 3},
+		},
+	})
+	c := NewCollector(client, log.NewNopLogger(), Options{Collectors: []string{"factions"}})
+
+	// The grids of B and the unowned grid have no faction, and the faction
+	// of the owner of the last grid is unknown.
+	expected := `
+# HELP space_engineers_faction_grid_count The number of grids owned by the members of each faction.
+# TYPE space_engineers_faction_grid_count gauge
+space_engineers_faction_grid_count{faction_tag=""} 2
+space_engineers_faction_grid_count{faction_tag="BLD"} 1
+space_engineers_faction_grid_count{faction_tag="unknown"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "space_engineers_faction_grid_count"); err != nil {
+		t.Error(err)
+	}
+
+	// The players that left and own no grid are forgotten.
+	srv.MustSetData("GET", "/v1/session/players", map[string]interface{}{"Players": []vrage_client.OnlinePlayerResponseData{}})
+	srv.MustSetData("GET", "/v1/session/grids", map[string]interface{}{
+		"Grids": []vrage_client.GridResponseData{{EntityId: 10, OwnerSteamId: 1}},
+	})
+	expected = `
+# HELP space_engineers_faction_member_count The number of known members of each faction.
+# TYPE space_engineers_faction_member_count gauge
+space_engineers_faction_member_count{faction_tag="BLD"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "space_engineers_faction_member_count"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	floatingObjectLabels    = []string{"kind"}
+	floatingObjectCountDesc = getSEDesc("floating_object", "count", "The number of floating objects on the server.", floatingObjectLabels)
+	floatingObjectMassDesc  = getSEDesc("floating_object_mass", "kilograms", "The total mass of the floating objects on the server.", floatingObjectLabels)
+)
+
+func init() {
+	registerCollector("floating_objects", defaultEnabled, newFloatingObjectCollector)
+}
+
+// Collects the floating objects by kind.
+type floatingObjectCollector struct {
+	client *vrage_client.VRageClient
+}
+
+func newFloatingObjectCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &floatingObjectCollector{client: client}
+}
+
+func (c *floatingObjectCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- floatingObjectCountDesc
+	ch <- floatingObjectMassDesc
+}
+
+func (c *floatingObjectCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	resp, err := c.client.GetFloatingObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	masses := make(map[string]float64)
+	for i := range resp.Data.FloatingObjects {
+		object := &resp.Data.FloatingObjects[i]
+		counts[object.Kind]++
+		masses[object.Kind] += object.Mass
+	}
+
+	for kind, count := range counts {
+		// space_engineers_floating_object_count
+		ch <- prometheus.MustNewConstMetric(
+			floatingObjectCountDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			kind,
+		)
+
+		// space_engineers_floating_object_mass_kilograms
+		ch <- prometheus.MustNewConstMetric(
+			floatingObjectMassDesc,
+			prometheus.GaugeValue,
+			masses[kind],
+			kind,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	gridLifecycleLabels = []string{"owner"}
+	gridsCreatedDesc    = getSEDesc("grids_created", "total", "The number of grids that appeared since the exporter started.", gridLifecycleLabels)
+	gridsRemovedDesc    = getSEDesc("grids_removed", "total", "The number of grids that disappeared since the exporter started.", gridLifecycleLabels)
+	gridsRenamedDesc    = getSEDesc("grids_renamed", "total", "The number of times a grid was renamed since the exporter started.", gridLifecycleLabels)
+	gridRemovedAgeDesc  = getSEDesc("grid_removed_age", "seconds", "The age of the grids when they disappeared, for grids that appeared after the exporter started.", gridLifecycleLabels)
+
+	// From one minute to one week.
+	gridAgeBuckets = []float64{60, 300, 900, 3600, 6 * 3600, 24 * 3600, 7 * 24 * 3600}
+)
+
+// The state of a grid as of the last poll.
+type trackedGrid struct {
+	name      string
+	owner     string
+	firstSeen time.Time
+	// The grid existed when the tracking started, so its age is unknown.
+	preexisting bool
+}
+
+// The ages of the removed grids of an owner.
+type ageHistogram struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+// Tracks the grids between polls to count the grids created, removed and
+// renamed.
+type gridLifecycle struct {
+	mu      sync.Mutex
+	started bool
+	grids   map[int64]trackedGrid
+	created map[string]uint64
+	removed map[string]uint64
+	renamed map[string]uint64
+	ages    map[string]*ageHistogram
+}
+
+func newGridLifecycle() *gridLifecycle {
+	return &gridLifecycle{
+		grids:   make(map[int64]trackedGrid),
+		created: make(map[string]uint64),
+		removed: make(map[string]uint64),
+		renamed: make(map[string]uint64),
+		ages:    make(map[string]*ageHistogram),
+	}
+}
+
+// Compare the given grids with the ones of the previous poll and record the
+// differences. The first poll only records the grids.
+func (l *gridLifecycle) update(grids []vrage_client.GridResponseData, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seen := make(map[int64]bool)
+	for i := range grids {
+		grid := &grids[i]
+		seen[grid.EntityId] = true
+
+		tracked, ok := l.grids[grid.EntityId]
+		if !ok {
+			tracked = trackedGrid{firstSeen: now, preexisting: !l.started}
+			if l.started {
+				l.created[grid.OwnerDisplayName]++
+			}
+		} else if tracked.name != grid.DisplayName {
+			l.renamed[grid.OwnerDisplayName]++
+		}
+
+		tracked.name = grid.DisplayName
+		tracked.owner = grid.OwnerDisplayName
+		l.grids[grid.EntityId] = tracked
+	}
+
+	for entityId, tracked := range l.grids {
+		if seen[entityId] {
+			continue
+		}
+		delete(l.grids, entityId)
+		l.removed[tracked.owner]++
+
+		if tracked.preexisting {
+			continue
+		}
+		hist, ok := l.ages[tracked.owner]
+		if !ok {
+			hist = &ageHistogram{buckets: make(map[float64]uint64)}
+			for _, bound := range gridAgeBuckets {
+				hist.buckets[bound] = 0
+			}
+			l.ages[tracked.owner] = hist
+		}
+		age := now.Sub(tracked.firstSeen).Seconds()
+		hist.count++
+		hist.sum += age
+		for _, bound := range gridAgeBuckets {
+			if age <= bound {
+				hist.buckets[bound]++
+			}
+		}
+	}
+
+	l.started = true
+}
+
+// Returns the time the grid with the given entity ID was first seen, if it
+// exists as of the last poll.
+func (l *gridLifecycle) firstSeen(entityId int64) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tracked, ok := l.grids[entityId]
+	return tracked.firstSeen, ok
+}
+
+func (l *gridLifecycle) describe(ch chan<- *prometheus.Desc) {
+	ch <- gridsCreatedDesc
+	ch <- gridsRemovedDesc
+	ch <- gridsRenamedDesc
+	ch <- gridRemovedAgeDesc
+}
+
+func (l *gridLifecycle) collect(ch chan<- prometheus.Metric) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// space_engineers_grids_created_total
+	for owner, count := range l.created {
+		ch <- prometheus.MustNewConstMetric(
+			gridsCreatedDesc,
+			prometheus.CounterValue,
+			float64(count),
+			owner,
+		)
+	}
+
+	// space_engineers_grids_removed_total
+	for owner, count := range l.removed {
+		ch <- prometheus.MustNewConstMetric(
+			gridsRemovedDesc,
+			prometheus.CounterValue,
+			float64(count),
+			owner,
+		)
+	}
+
+	// space_engineers_grids_renamed_total
+	for owner, count := range l.renamed {
+		ch <- prometheus.MustNewConstMetric(
+			gridsRenamedDesc,
+			prometheus.CounterValue,
+			float64(count),
+			owner,
+		)
+	}
+
+	// space_engineers_grid_removed_age_seconds
+	for owner, hist := range l.ages {
+		// The histogram keeps a reference to the buckets, which are
+		// updated by the next poll.
+		buckets := make(map[float64]uint64, len(hist.buckets))
+		for bound, count := range hist.buckets {
+			buckets[bound] = count
+		}
+		ch <- prometheus.MustNewConstHistogram(
+			gridRemovedAgeDesc,
+			hist.count,
+			hist.sum,
+			buckets,
+			owner,
+		)
+	}
+}
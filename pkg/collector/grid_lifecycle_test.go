@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+func TestGridLifecycleFirstPoll(t *testing.T) {
+	lifecycle := newGridLifecycle()
+	start := time.Unix(1700000000, 0)
+	grid := func(entityId int64, name string, owner string) vrage_client.GridResponseData {
+		return vrage_client.GridResponseData{EntityId: entityId, DisplayName: name, OwnerDisplayName: owner}
+	}
+
+	// The first poll only records the grids, whose age is unknown.
+	lifecycle.update([]vrage_client.GridResponseData{grid(1, "Base", "A"), grid(2, "Miner", "B")}, start)
+	if len(lifecycle.created) != 0 || len(lifecycle.removed) != 0 || len(lifecycle.renamed) != 0 {
+		t.Fatalf("expected no changes after the first poll, got created %v, removed %v, renamed %v", lifecycle.created, lifecycle.removed, lifecycle.renamed)
+	}
+
+	// A grid of the first poll is removed without an age, and a new grid
+	// is counted as created.
+	lifecycle.update([]vrage_client.GridResponseData{grid(2, "Miner", "B"), grid(3, "Rover", "A")}, start.Add(time.Minute))
+	if lifecycle.created["A"] != 1 || lifecycle.removed["A"] != 1 {
+		t.Errorf("expected 1 grid created and removed by A, got %d and %d", lifecycle.created["A"], lifecycle.removed["A"])
+	}
+	if len(lifecycle.ages) != 0 {
+		t.Errorf("expected no removed grid age, got %v", lifecycle.ages)
+	}
+	if firstSeen, ok := lifecycle.firstSeen(2); !ok || !firstSeen.Equal(start) {
+		t.Errorf("expected grid 2 first seen at %v, got %v", start, firstSeen)
+	}
+	if firstSeen, ok := lifecycle.firstSeen(3); !ok || !firstSeen.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected grid 3 first seen at %v, got %v", start.Add(time.Minute), firstSeen)
+	}
+	if _, ok := lifecycle.firstSeen(1); ok {
+		t.Errorf("expected removed grid 1 to be forgotten")
+	}
+
+	// The grids created after the first poll are removed with their age.
+	lifecycle.update([]vrage_client.GridResponseData{grid(2, "Miner", "B")}, start.Add(3*time.Minute))
+	if hist := lifecycle.ages["A"]; hist == nil || hist.count != 1 || hist.sum != 120 {
+		t.Errorf("expected one removed grid aged 120 seconds, got %+v", hist)
+	}
+}
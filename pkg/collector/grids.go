@@ -0,0 +1,240 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	gridLabels    = []string{"powered", "grid_size"}
+	pcuLabels     = []string{"powered", "grid_size", "owner"}
+	gridCountDesc = getSEDesc("grid", "count", "The number of grids on the server.", gridLabels)
+	pcuCountDesc  = getSEDesc("pcu", "count", "The number of PCUs used on the server.", pcuLabels)
+
+	gridDetailLabels  = []string{"entity_id", "name"}
+	gridBlocksDesc    = getSEDesc("grid", "blocks", "The number of blocks of each grid.", gridDetailLabels)
+	gridMassDesc      = getSEDesc("grid_mass", "kilograms", "The mass of each grid.", gridDetailLabels)
+	gridSpeedDesc     = getSEDesc("grid_speed", "meters_per_second", "The linear speed of each grid.", gridDetailLabels)
+	gridPcuDesc       = getSEDesc("grid", "pcu", "The number of PCUs used by each grid.", gridDetailLabels)
+	gridDistanceDesc  = getSEDesc("grid_player_distance", "meters", "The distance from each grid to the nearest player.", gridDetailLabels)
+	gridFirstSeenDesc = getSEDesc("grid_first_seen", "timestamp_seconds", "The time each grid was first seen, or the time of the first poll for the grids that already existed.", gridDetailLabels)
+	gridsExportedDesc = getSEDesc("grid_details_exported", "count", "The number of grids exported with per-grid metrics.", nil)
+)
+
+const (
+	GridRankByPCU  = "pcu"
+	GridRankByMass = "mass"
+)
+
+// Options limiting the grids exported with per-grid metrics.
+type GridOptions struct {
+	// The maximum number of grids exported with per-grid metrics. Per-grid
+	// metrics are disabled when 0.
+	TopN int
+	// Rank the grids by GridRankByPCU or GridRankByMass when selecting the
+	// top N.
+	RankBy string
+	// Only grids whose name matches NameInclude, and does not match
+	// NameExclude, are considered. Either may be nil.
+	NameInclude *regexp.Regexp
+	NameExclude *regexp.Regexp
+}
+
+// Returns the grids selected for per-grid metrics, largest first.
+func (o GridOptions) selectGrids(grids []vrage_client.GridResponseData) []*vrage_client.GridResponseData {
+	if o.TopN <= 0 {
+		return nil
+	}
+
+	var selected []*vrage_client.GridResponseData
+	for i := range grids {
+		grid := &grids[i]
+		if o.NameInclude != nil && !o.NameInclude.MatchString(grid.DisplayName) {
+			continue
+		}
+		if o.NameExclude != nil && o.NameExclude.MatchString(grid.DisplayName) {
+			continue
+		}
+		selected = append(selected, grid)
+	}
+
+	rank := func(grid *vrage_client.GridResponseData) float64 {
+		if o.RankBy == GridRankByMass {
+			return grid.Mass
+		}
+		return float64(grid.PCU)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if ri, rj := rank(selected[i]), rank(selected[j]); ri != rj {
+			return ri > rj
+		}
+		return selected[i].EntityId < selected[j].EntityId
+	})
+
+	if len(selected) > o.TopN {
+		selected = selected[:o.TopN]
+	}
+	return selected
+}
+
+func init() {
+	registerCollector("grids", defaultEnabled, newGridCollector)
+}
+
+// Collects the grid counts and PCU usage, the details of the largest grids and
+// the grids created and removed between polls.
+type gridCollector struct {
+	options   GridOptions
+	now       func() time.Time
+	lifecycle *gridLifecycle
+}
+
+func newGridCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &gridCollector{options: options.Grids, now: options.Now, lifecycle: newGridLifecycle()}
+}
+
+func (c *gridCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- gridCountDesc
+	ch <- pcuCountDesc
+	ch <- gridBlocksDesc
+	ch <- gridMassDesc
+	ch <- gridSpeedDesc
+	ch <- gridPcuDesc
+	ch <- gridDistanceDesc
+	ch <- gridFirstSeenDesc
+	ch <- gridsExportedDesc
+	c.lifecycle.describe(ch)
+}
+
+func (c *gridCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	resp, err := cache.GetGrids(ctx)
+	if err != nil {
+		return err
+	}
+
+	owners := make(map[string]bool)
+	for i := range resp.Data.Grids {
+		grid := &resp.Data.Grids[i]
+		if ok := owners[grid.OwnerDisplayName]; !ok {
+			owners[grid.OwnerDisplayName] = true
+		}
+	}
+
+	for _, powered := range []bool{true, false} {
+		for _, size := range []string{"Large", "Small"} {
+			count := 0
+			for i := range resp.Data.Grids {
+				grid := &resp.Data.Grids[i]
+				if grid.IsPowered == powered && grid.GridSize == size {
+					count++
+				}
+			}
+			ch <- prometheus.MustNewConstMetric(
+				gridCountDesc,
+				prometheus.GaugeValue,
+				float64(count),
+				fmt.Sprintf("%v", powered),
+				size,
+			)
+
+			for owner := range owners {
+				count = 0
+				for i := range resp.Data.Grids {
+					grid := &resp.Data.Grids[i]
+					if grid.IsPowered == powered && grid.GridSize == size && grid.OwnerDisplayName == owner {
+						count += int(grid.PCU)
+					}
+				}
+				ch <- prometheus.MustNewConstMetric(
+					pcuCountDesc,
+					prometheus.GaugeValue,
+					float64(count),
+					fmt.Sprintf("%v", powered),
+					size,
+					owner,
+				)
+			}
+		}
+	}
+
+	c.lifecycle.update(resp.Data.Grids, c.now())
+
+	selected := c.options.selectGrids(resp.Data.Grids)
+	for _, grid := range selected {
+		entityId := fmt.Sprintf("%v", grid.EntityId)
+
+		// space_engineers_grid_blocks
+		ch <- prometheus.MustNewConstMetric(
+			gridBlocksDesc,
+			prometheus.GaugeValue,
+			float64(grid.BlocksCount),
+			entityId,
+			grid.DisplayName,
+		)
+
+		// space_engineers_grid_mass_kilograms
+		ch <- prometheus.MustNewConstMetric(
+			gridMassDesc,
+			prometheus.GaugeValue,
+			grid.Mass,
+			entityId,
+			grid.DisplayName,
+		)
+
+		// space_engineers_grid_speed_meters_per_second
+		ch <- prometheus.MustNewConstMetric(
+			gridSpeedDesc,
+			prometheus.GaugeValue,
+			grid.LinearSpeed,
+			entityId,
+			grid.DisplayName,
+		)
+
+		// space_engineers_grid_pcu
+		ch <- prometheus.MustNewConstMetric(
+			gridPcuDesc,
+			prometheus.GaugeValue,
+			float64(grid.PCU),
+			entityId,
+			grid.DisplayName,
+		)
+
+		// space_engineers_grid_player_distance_meters
+		ch <- prometheus.MustNewConstMetric(
+			gridDistanceDesc,
+			prometheus.GaugeValue,
+			grid.DistanceToPlayer,
+			entityId,
+			grid.DisplayName,
+		)
+
+		// space_engineers_grid_first_seen_timestamp_seconds
+		if firstSeen, ok := c.lifecycle.firstSeen(grid.EntityId); ok {
+			ch <- prometheus.MustNewConstMetric(
+				gridFirstSeenDesc,
+				prometheus.GaugeValue,
+				float64(firstSeen.UnixNano())/1e9,
+				entityId,
+				grid.DisplayName,
+			)
+		}
+	}
+
+	// space_engineers_grid_details_exported_count
+	ch <- prometheus.MustNewConstMetric(
+		gridsExportedDesc,
+		prometheus.GaugeValue,
+		float64(len(selected)),
+	)
+
+	c.lifecycle.collect(ch)
+
+	return nil
+}
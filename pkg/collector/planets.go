@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	planetLabels = []string{"display_name", "entity_id", "x", "y", "z"}
+	planetDesc   = getSEDesc("planet", "info", "Information about the planets.", planetLabels)
+)
+
+func init() {
+	registerCollector("planets", defaultEnabled, newPlanetCollector)
+}
+
+// Collects the planets of the world.
+type planetCollector struct{}
+
+func newPlanetCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &planetCollector{}
+}
+
+func (c *planetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- planetDesc
+}
+
+func (c *planetCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	resp, err := cache.GetPlanets(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range resp.Data.Planets {
+		planet := &resp.Data.Planets[i]
+		ch <- prometheus.MustNewConstMetric(
+			planetDesc,
+			prometheus.GaugeValue,
+			1,
+			planet.DisplayName,
+			fmt.Sprintf("%v", planet.EntityId),
+			fmt.Sprintf("%v", planet.Position.X),
+			fmt.Sprintf("%v", planet.Position.Y),
+			fmt.Sprintf("%v", planet.Position.Z),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	onlinePlayerLabels      = []string{"steam_id", "display_name"}
+	playerFactionLabels     = []string{"steam_id", "display_name", "faction_name", "faction_tag"}
+	playerPingDesc          = getSEDesc("player_ping", "seconds", "The network latency of each connected player.", onlinePlayerLabels)
+	playerPromoteLevelDesc  = getSEDesc("player", "promote_level", "The promote level of each connected player (0 = player, 4 = owner).", onlinePlayerLabels)
+	playerFactionMemberDesc = getSEDesc("player_faction", "info", "The faction membership of each connected player.", playerFactionLabels)
+)
+
+func init() {
+	registerCollector("players", defaultEnabled, newPlayerCollector)
+}
+
+// Options of the players collector.
+type PlayerOptions struct {
+	// The file in which the session history of the players is kept. The
+	// history is lost when the exporter restarts if empty.
+	StateFile string
+	// The longest time between two polls credited to the players connected
+	// at both. A player seen again after a longer time starts a new session.
+	// Defaults to 5 minutes when not positive.
+	MaxSessionGap time.Duration
+}
+
+// Collects the players connected to the server and their session history.
+type playerCollector struct {
+	now      func() time.Time
+	sessions *sessionTracker
+}
+
+func newPlayerCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &playerCollector{
+		now:      options.Now,
+		sessions: newSessionTracker(options.Players.StateFile, options.Players.MaxSessionGap, logger),
+	}
+}
+
+func (c *playerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- playerPingDesc
+	ch <- playerPromoteLevelDesc
+	ch <- playerFactionMemberDesc
+	c.sessions.describe(ch)
+}
+
+func (c *playerCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	resp, err := cache.GetPlayers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range resp.Data.Players {
+		player := &resp.Data.Players[i]
+		steamId := fmt.Sprintf("%v", player.SteamID)
+
+		// space_engineers_player_ping_seconds
+		ch <- prometheus.MustNewConstMetric(
+			playerPingDesc,
+			prometheus.GaugeValue,
+			float64(player.Ping)/1000,
+			steamId,
+			player.DisplayName,
+		)
+
+		// space_engineers_player_promote_level
+		ch <- prometheus.MustNewConstMetric(
+			playerPromoteLevelDesc,
+			prometheus.GaugeValue,
+			float64(player.PromoteLevel),
+			steamId,
+			player.DisplayName,
+		)
+
+		// space_engineers_player_faction_info
+		ch <- prometheus.MustNewConstMetric(
+			playerFactionMemberDesc,
+			prometheus.GaugeValue,
+			1,
+			steamId,
+			player.DisplayName,
+			player.FactionName,
+			player.FactionTag,
+		)
+	}
+
+	c.sessions.update(resp.Data.Players, c.now())
+	c.sessions.collect(ch)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var snapshotAgeDesc = getSEDesc("snapshot_age", "seconds", "The number of seconds since the served metrics were collected.", nil)
+
+// Periodically collects the metrics of a Collector in the background and
+// serves the latest snapshot, so that scrapes do not query the remote API.
+type Poller struct {
+	collector Collector
+	interval  time.Duration
+	timeout   time.Duration
+
+	mu       sync.RWMutex
+	snapshot []prometheus.Metric
+	updated  time.Time
+}
+
+// Create a new poller collecting the metrics of the given collector every
+// interval. Each collection is bounded by the given timeout.
+func NewPoller(collector Collector, interval time.Duration, timeout time.Duration) *Poller {
+	return &Poller{collector: collector, interval: interval, timeout: timeout}
+}
+
+// Poll the remote API until the context is cancelled.
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect the metrics and replace the current snapshot.
+func (p *Poller) poll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	begin := time.Now()
+	snapshot := bufferMetrics(p.collector.WithContext(ctx).Collect)
+
+	p.mu.Lock()
+	p.snapshot = snapshot
+	p.updated = begin
+	p.mu.Unlock()
+
+	level.Debug(p.collector.logger).Log("msg", "Updated snapshot", "metrics", len(snapshot), "duration_seconds", time.Since(begin).Seconds())
+}
+
+func (p *Poller) Describe(ch chan<- *prometheus.Desc) {
+	p.collector.Describe(ch)
+	ch <- snapshotAgeDesc
+}
+
+func (p *Poller) Collect(ch chan<- prometheus.Metric) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	// Nothing to serve until the first poll completes.
+	if p.updated.IsZero() {
+		return
+	}
+
+	for _, metric := range p.snapshot {
+		ch <- metric
+	}
+
+	// space_engineers_snapshot_age_seconds
+	ch <- prometheus.MustNewConstMetric(
+		snapshotAgeDesc,
+		prometheus.GaugeValue,
+		time.Since(p.updated).Seconds(),
+	)
+}
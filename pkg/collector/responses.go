@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+// A response of the remote API, queried at most once.
+type memoResponse[R any] struct {
+	once sync.Once
+	resp *R
+	err  error
+}
+
+// Returns the response, querying it with the given function on the first call.
+// Concurrent callers wait for the first query to complete.
+func (m *memoResponse[R]) get(query func() (*R, error)) (*R, error) {
+	m.once.Do(func() {
+		m.resp, m.err = query()
+	})
+	return m.resp, m.err
+}
+
+// The responses of the remote API used by several sub-collectors, shared
+// between the sub-collectors during a scrape so that each endpoint is queried
+// once. The responses must not be modified.
+type responseCache struct {
+	client *vrage_client.VRageClient
+
+	grids      memoResponse[vrage_client.GridResponse]
+	players    memoResponse[vrage_client.PlayersResponse]
+	characters memoResponse[vrage_client.CharactersResponse]
+	planets    memoResponse[vrage_client.PlanetResponse]
+}
+
+func newResponseCache(client *vrage_client.VRageClient) *responseCache {
+	return &responseCache{client: client}
+}
+
+func (c *responseCache) GetGrids(ctx context.Context) (*vrage_client.GridResponse, error) {
+	return c.grids.get(func() (*vrage_client.GridResponse, error) {
+		return c.client.GetGrids(ctx)
+	})
+}
+
+func (c *responseCache) GetPlayers(ctx context.Context) (*vrage_client.PlayersResponse, error) {
+	return c.players.get(func() (*vrage_client.PlayersResponse, error) {
+		return c.client.GetPlayers(ctx)
+	})
+}
+
+func (c *responseCache) GetCharacters(ctx context.Context) (*vrage_client.CharactersResponse, error) {
+	return c.characters.get(func() (*vrage_client.CharactersResponse, error) {
+		return c.client.GetCharacters(ctx)
+	})
+}
+
+func (c *responseCache) GetPlanets(ctx context.Context) (*vrage_client.PlanetResponse, error) {
+	return c.planets.get(func() (*vrage_client.PlanetResponse, error) {
+		return c.client.GetPlanets(ctx)
+	})
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	lastSaveTimestampDesc = getSEDesc("exporter_last_save", "timestamp_seconds", "The time of the last world save triggered through the exporter.", nil)
+	lastSaveDurationDesc  = getSEDesc("exporter_last_save", "duration_seconds", "The duration of the last world save triggered through the exporter.", nil)
+	lastSaveSuccessDesc   = getSEDesc("exporter_last_save", "success", "Did the last world save triggered through the exporter succeed.", nil)
+)
+
+// Records the outcome of the last world save triggered through the exporter.
+type saveTracker struct {
+	mu       sync.Mutex
+	started  time.Time
+	duration time.Duration
+	success  bool
+}
+
+// Save the world and record the outcome.
+func (c Collector) SaveWorld(ctx context.Context) error {
+	started := time.Now()
+	err := c.client.SaveWorld(ctx)
+	duration := time.Since(started)
+
+	c.saves.mu.Lock()
+	defer c.saves.mu.Unlock()
+	c.saves.started = started
+	c.saves.duration = duration
+	c.saves.success = err == nil
+
+	return err
+}
+
+func (c Collector) CollectSaves(ch chan<- prometheus.Metric) {
+	c.saves.mu.Lock()
+	defer c.saves.mu.Unlock()
+
+	// Nothing to report until a save has been triggered.
+	if c.saves.started.IsZero() {
+		return
+	}
+
+	successVal := 0.0
+	if c.saves.success {
+		successVal = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		lastSaveTimestampDesc,
+		prometheus.GaugeValue,
+		float64(c.saves.started.UnixNano())/1e9,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lastSaveDurationDesc,
+		prometheus.GaugeValue,
+		c.saves.duration.Seconds(),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lastSaveSuccessDesc,
+		prometheus.GaugeValue,
+		successVal,
+	)
+}
+
+// Returns a handler that saves the world when it receives a POST request.
+func (c Collector) SaveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		level.Info(c.logger).Log("msg", "Saving world")
+		if err := c.SaveWorld(r.Context()); err != nil {
+			level.Error(c.logger).Log("msg", "Failed to save world", "err", err)
+			http.Error(w, "failed to save world", http.StatusBadGateway)
+			return
+		}
+		level.Info(c.logger).Log("msg", "World saved")
+	})
+}
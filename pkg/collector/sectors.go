@@ -0,0 +1,240 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	sectorLabels             = []string{"sector_x", "sector_y", "sector_z"}
+	sectorGridCountDesc      = getSEDesc("sector_grid", "count", "The number of grids in each sector of the world.", sectorLabels)
+	sectorPcuDesc            = getSEDesc("sector", "pcu", "The number of PCUs used by the grids in each sector of the world.", sectorLabels)
+	sectorMassDesc           = getSEDesc("sector_grid_mass", "kilograms", "The mass of the grids in each sector of the world.", sectorLabels)
+	sectorCharacterCountDesc = getSEDesc("sector_character", "count", "The number of characters in each sector of the world.", sectorLabels)
+
+	planetProximityLabels        = []string{"display_name", "entity_id", "within_km"}
+	planetProximityGridCountDesc = getSEDesc("planet_proximity_grid", "count", "The number of grids within a distance of the centre of each planet.", planetProximityLabels)
+	planetProximityPcuDesc       = getSEDesc("planet_proximity", "pcu", "The number of PCUs used by the grids within a distance of the centre of each planet.", planetProximityLabels)
+	planetProximityMassDesc      = getSEDesc("planet_proximity_grid_mass", "kilograms", "The mass of the grids within a distance of the centre of each planet.", planetProximityLabels)
+)
+
+func init() {
+	registerCollector("sectors", defaultDisabled, newSectorCollector)
+}
+
+// The default edge length of the sectors, in kilometres.
+const defaultSectorSizeKm = 100
+
+// Options of the sectors collector.
+type SectorOptions struct {
+	// The edge length of the cubic sectors the world is divided into, in
+	// kilometres. Defaults to 100 when not positive.
+	SizeKm float64
+	// The distances from the centre of the planets, in kilometres, within
+	// which the grids are aggregated.
+	PlanetDistancesKm []float64
+}
+
+// The coordinates of a sector, counted in sectors from the origin of the
+// world.
+type sector struct {
+	x, y, z int64
+}
+
+func (s sector) labels() []string {
+	return []string{
+		strconv.FormatInt(s.x, 10),
+		strconv.FormatInt(s.y, 10),
+		strconv.FormatInt(s.z, 10),
+	}
+}
+
+// The grids and characters in an area of the world.
+type areaTotals struct {
+	grids      uint
+	pcu        uint
+	mass       float64
+	characters uint
+}
+
+// Aggregates the grids and characters by the sector they are in, and the
+// grids by their distance to the planets. The planets are only queried when
+// distances are configured.
+type sectorCollector struct {
+	options SectorOptions
+}
+
+func newSectorCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	// A sector size that is not positive would put the grids in sectors
+	// with meaningless coordinates.
+	if !(options.Sectors.SizeKm > 0) {
+		options.Sectors.SizeKm = defaultSectorSizeKm
+	}
+	// Duplicate distances would export the same metrics twice.
+	distances := append([]float64(nil), options.Sectors.PlanetDistancesKm...)
+	slices.Sort(distances)
+	options.Sectors.PlanetDistancesKm = slices.Compact(distances)
+	return &sectorCollector{options: options.Sectors}
+}
+
+// Returns the sector containing the given position.
+func (c *sectorCollector) sectorOf(pos vrage_client.EntityPosition) sector {
+	size := c.options.SizeKm * 1000
+	return sector{
+		x: int64(math.Floor(pos.X / size)),
+		y: int64(math.Floor(pos.Y / size)),
+		z: int64(math.Floor(pos.Z / size)),
+	}
+}
+
+// Returns the distance between two positions, in meters.
+func distance(a, b vrage_client.EntityPosition) float64 {
+	return math.Sqrt((a.X-b.X)*(a.X-b.X) + (a.Y-b.Y)*(a.Y-b.Y) + (a.Z-b.Z)*(a.Z-b.Z))
+}
+
+func (c *sectorCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sectorGridCountDesc
+	ch <- sectorPcuDesc
+	ch <- sectorMassDesc
+	ch <- sectorCharacterCountDesc
+	ch <- planetProximityGridCountDesc
+	ch <- planetProximityPcuDesc
+	ch <- planetProximityMassDesc
+}
+
+func (c *sectorCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	grids, err := cache.GetGrids(ctx)
+	if err != nil {
+		return err
+	}
+
+	characters, err := cache.GetCharacters(ctx)
+	if err != nil {
+		return err
+	}
+
+	planets := &vrage_client.PlanetResponse{}
+	if len(c.options.PlanetDistancesKm) > 0 {
+		if planets, err = cache.GetPlanets(ctx); err != nil {
+			return err
+		}
+	}
+
+	sectors := make(map[sector]*areaTotals)
+	getSector := func(pos vrage_client.EntityPosition) *areaTotals {
+		s := c.sectorOf(pos)
+		t, ok := sectors[s]
+		if !ok {
+			t = &areaTotals{}
+			sectors[s] = t
+		}
+		return t
+	}
+
+	for i := range grids.Data.Grids {
+		grid := &grids.Data.Grids[i]
+		t := getSector(grid.Position)
+		t.grids++
+		t.pcu += grid.PCU
+		t.mass += grid.Mass
+	}
+	for i := range characters.Data.Characters {
+		getSector(characters.Data.Characters[i].Position).characters++
+	}
+
+	for s, t := range sectors {
+		labels := s.labels()
+
+		// space_engineers_sector_grid_count
+		ch <- prometheus.MustNewConstMetric(
+			sectorGridCountDesc,
+			prometheus.GaugeValue,
+			float64(t.grids),
+			labels...,
+		)
+
+		// space_engineers_sector_pcu
+		ch <- prometheus.MustNewConstMetric(
+			sectorPcuDesc,
+			prometheus.GaugeValue,
+			float64(t.pcu),
+			labels...,
+		)
+
+		// space_engineers_sector_grid_mass_kilograms
+		ch <- prometheus.MustNewConstMetric(
+			sectorMassDesc,
+			prometheus.GaugeValue,
+			t.mass,
+			labels...,
+		)
+
+		// space_engineers_sector_character_count
+		ch <- prometheus.MustNewConstMetric(
+			sectorCharacterCountDesc,
+			prometheus.GaugeValue,
+			float64(t.characters),
+			labels...,
+		)
+	}
+
+	for i := range planets.Data.Planets {
+		planet := &planets.Data.Planets[i]
+
+		// Like histogram buckets, each grid is counted in every distance
+		// it is within.
+		buckets := make([]areaTotals, len(c.options.PlanetDistancesKm))
+		for j := range grids.Data.Grids {
+			grid := &grids.Data.Grids[j]
+			d := distance(grid.Position, planet.Position) / 1000
+			for k, within := range c.options.PlanetDistancesKm {
+				if d <= within {
+					buckets[k].grids++
+					buckets[k].pcu += grid.PCU
+					buckets[k].mass += grid.Mass
+				}
+			}
+		}
+
+		for k, within := range c.options.PlanetDistancesKm {
+			labels := []string{
+				planet.DisplayName,
+				fmt.Sprintf("%v", planet.EntityId),
+				strconv.FormatFloat(within, 'f', -1, 64),
+			}
+
+			// space_engineers_planet_proximity_grid_count
+			ch <- prometheus.MustNewConstMetric(
+				planetProximityGridCountDesc,
+				prometheus.GaugeValue,
+				float64(buckets[k].grids),
+				labels...,
+			)
+
+			// space_engineers_planet_proximity_pcu
+			ch <- prometheus.MustNewConstMetric(
+				planetProximityPcuDesc,
+				prometheus.GaugeValue,
+				float64(buckets[k].pcu),
+				labels...,
+			)
+
+			// space_engineers_planet_proximity_grid_mass_kilograms
+			ch <- prometheus.MustNewConstMetric(
+				planetProximityMassDesc,
+				prometheus.GaugeValue,
+				buckets[k].mass,
+				labels...,
+			)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	serverInfoLabels      = []string{"server_name", "world_name", "version", "server_id"}
+	serverInfoDesc        = getSEDesc("", "info", "Information about the server", serverInfoLabels)
+	readyDesc             = getSEDesc("", "ready", "Is the server ready?", nil)
+	upTimeDesc            = getSEDesc("up", "seconds", "The number of seconds that the server has been up.", nil)
+	playerCountDesc       = getSEDesc("player", "count", "The number of players currently connected to the server.", nil)
+	simulationCpuLoadDesc = getSEDesc("simulation_cpu_load", "percent", "The simulation thread CPU load.", nil)
+	simulationSpeedDesc   = getSEDesc("", "simulation_speed", "The simulation speed factor.", nil)
+	pcuUsedDesc           = getSEDesc("pcu_used", "total", "The total number of PCU used.", nil)
+	piratePcuUsedDesc     = getSEDesc("pirate_pcu_used", "total", "The total number of PCU used by pirate factions.", nil)
+)
+
+func init() {
+	registerCollector("server", defaultEnabled, newServerCollector)
+}
+
+// Collects the server details.
+type serverCollector struct {
+	client *vrage_client.VRageClient
+}
+
+func newServerCollector(client *vrage_client.VRageClient, logger log.Logger, options Options) subCollector {
+	return &serverCollector{client: client}
+}
+
+func (c *serverCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serverInfoDesc
+	ch <- readyDesc
+	ch <- upTimeDesc
+	ch <- playerCountDesc
+	ch <- simulationCpuLoadDesc
+	ch <- simulationSpeedDesc
+	ch <- pcuUsedDesc
+	ch <- piratePcuUsedDesc
+}
+
+func (c *serverCollector) Update(ctx context.Context, cache *responseCache, ch chan<- prometheus.Metric) error {
+	serverInfo, err := c.client.GetServerDetails(ctx)
+	if err != nil {
+		return err
+	}
+
+	// space_engineers_info
+	ch <- prometheus.MustNewConstMetric(
+		serverInfoDesc,
+		prometheus.GaugeValue,
+		1,
+		serverInfo.Data.ServerName,
+		serverInfo.Data.WorldName,
+		serverInfo.Data.Version,
+		fmt.Sprintf("%v", serverInfo.Data.ServerId),
+	)
+
+	// space_engineers_ready
+	var readyVal float64
+	if serverInfo.Data.IsReady {
+		readyVal = 1
+	}
+	ch <- prometheus.MustNewConstMetric(
+		readyDesc,
+		prometheus.GaugeValue,
+		readyVal,
+	)
+
+	// space_engineers_up_seconds
+	ch <- prometheus.MustNewConstMetric(
+		upTimeDesc,
+		prometheus.CounterValue,
+		float64(serverInfo.Data.TotalTime),
+	)
+
+	// space_engineers_player_count
+	ch <- prometheus.MustNewConstMetric(
+		playerCountDesc,
+		prometheus.GaugeValue,
+		float64(serverInfo.Data.Players),
+	)
+
+	// space_engineers_simulation_cpu_load_percent
+	ch <- prometheus.MustNewConstMetric(
+		simulationCpuLoadDesc,
+		prometheus.GaugeValue,
+		serverInfo.Data.SimulationCpuLoad,
+	)
+
+	// space_engineers_simulation_speed
+	ch <- prometheus.MustNewConstMetric(
+		simulationSpeedDesc,
+		prometheus.GaugeValue,
+		serverInfo.Data.SimSpeed,
+	)
+
+	// space_engineers_pcu_used_total
+	ch <- prometheus.MustNewConstMetric(
+		pcuUsedDesc,
+		prometheus.GaugeValue,
+		float64(serverInfo.Data.UsedPCU),
+	)
+
+	// space_engineers_pirate_pcu_used_total
+	ch <- prometheus.MustNewConstMetric(
+		piratePcuUsedDesc,
+		prometheus.GaugeValue,
+		float64(serverInfo.Data.PirateUsedPCU),
+	)
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+var (
+	playerSessionsDesc      = getSEDesc("player_sessions", "total", "The number of sessions of each player.", onlinePlayerLabels)
+	playerOnlineSecondsDesc = getSEDesc("player_online_seconds", "total", "The time each player spent connected to the server.", onlinePlayerLabels)
+	playerLastSeenDesc      = getSEDesc("player_last_seen", "timestamp_seconds", "The last time each player was seen connected to the server.", onlinePlayerLabels)
+)
+
+// The session history of a player.
+type playerSession struct {
+	DisplayName   string    `json:"display_name"`
+	Sessions      uint64    `json:"sessions"`
+	OnlineSeconds float64   `json:"online_seconds"`
+	LastSeen      time.Time `json:"last_seen"`
+	Online        bool      `json:"online"`
+}
+
+// The default longest time between two polls credited to the players.
+const defaultMaxSessionGap = 5 * time.Minute
+
+// Tracks the sessions of the players between polls, optionally persisting
+// them to a file so that the counters survive restarts of the exporter.
+type sessionTracker struct {
+	mu        sync.Mutex
+	stateFile string
+	// The longest time between two polls credited to the players seen at
+	// both. The players may have left and come back during a longer gap, so
+	// it ends their session.
+	maxGap  time.Duration
+	logger  log.Logger
+	players map[uint64]*playerSession
+}
+
+// Create a session tracker, loading the state from the given file if it
+// exists. The state is not persisted when the file is empty.
+func newSessionTracker(stateFile string, maxGap time.Duration, logger log.Logger) *sessionTracker {
+	if maxGap <= 0 {
+		maxGap = defaultMaxSessionGap
+	}
+	t := &sessionTracker{
+		stateFile: stateFile,
+		maxGap:    maxGap,
+		logger:    logger,
+		players:   make(map[uint64]*playerSession),
+	}
+
+	if stateFile != "" {
+		if err := t.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			level.Error(logger).Log("msg", "Failed to load player session state", "path", stateFile, "err", err)
+		}
+	}
+
+	return t
+}
+
+func (t *sessionTracker) load() error {
+	data, err := os.ReadFile(t.stateFile)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &t.players)
+}
+
+// Write the state to a temporary file and move it in place, so that a crash
+// never leaves a truncated state file behind.
+func (t *sessionTracker) save() error {
+	data, err := json.Marshal(t.players)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(t.stateFile), filepath.Base(t.stateFile)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), t.stateFile)
+}
+
+// Record the players online at the given time. A player that was not online
+// at the previous poll, or was last seen more than the maximum gap ago, starts
+// a new session. The other players are credited with the time since they were
+// last seen.
+func (t *sessionTracker) update(players []vrage_client.OnlinePlayerResponseData, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	online := make(map[uint64]bool)
+	for i := range players {
+		player := &players[i]
+		online[player.SteamID] = true
+
+		session, ok := t.players[player.SteamID]
+		if !ok {
+			session = &playerSession{}
+			t.players[player.SteamID] = session
+		}
+
+		if gap := now.Sub(session.LastSeen); session.Online && gap >= 0 && gap <= t.maxGap {
+			session.OnlineSeconds += gap.Seconds()
+		} else {
+			session.Sessions++
+			session.Online = true
+		}
+		session.DisplayName = player.DisplayName
+		session.LastSeen = now
+	}
+
+	for steamId, session := range t.players {
+		if !online[steamId] {
+			session.Online = false
+		}
+	}
+
+	if t.stateFile != "" {
+		if err := t.save(); err != nil {
+			level.Error(t.logger).Log("msg", "Failed to save player session state", "path", t.stateFile, "err", err)
+		}
+	}
+}
+
+func (t *sessionTracker) describe(ch chan<- *prometheus.Desc) {
+	ch <- playerSessionsDesc
+	ch <- playerOnlineSecondsDesc
+	ch <- playerLastSeenDesc
+}
+
+func (t *sessionTracker) collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for steamId, session := range t.players {
+		labels := []string{fmt.Sprintf("%v", steamId), session.DisplayName}
+
+		// space_engineers_player_sessions_total
+		ch <- prometheus.MustNewConstMetric(
+			playerSessionsDesc,
+			prometheus.CounterValue,
+			float64(session.Sessions),
+			labels...,
+		)
+
+		// space_engineers_player_online_seconds_total
+		ch <- prometheus.MustNewConstMetric(
+			playerOnlineSecondsDesc,
+			prometheus.CounterValue,
+			session.OnlineSeconds,
+			labels...,
+		)
+
+		// space_engineers_player_last_seen_timestamp_seconds
+		ch <- prometheus.MustNewConstMetric(
+			playerLastSeenDesc,
+			prometheus.GaugeValue,
+			float64(session.LastSeen.UnixNano())/1e9,
+			labels...,
+		)
+	}
+}
@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+func TestSessionTrackerGap(t *testing.T) {
+	tracker := newSessionTracker("", time.Minute, log.NewNopLogger())
+	players := []vrage_client.OnlinePlayerResponseData{{SteamID: 1, DisplayName: "A"}}
+	start := time.Unix(1700000000, 0)
+
+	for _, offset := range []time.Duration{
+		0,
+		30 * time.Second,
+		60 * time.Second,
+		// The player may have left and come back during a longer gap,
+		// which is not credited and starts a new session.
+		time.Hour,
+		time.Hour + 30*time.Second,
+	} {
+		tracker.update(players, start.Add(offset))
+	}
+
+	session := tracker.players[1]
+	if session.Sessions != 2 {
+		t.Errorf("expected 2 sessions, got %d", session.Sessions)
+	}
+	if session.OnlineSeconds != 90 {
+		t.Errorf("expected 90 seconds online, got %v", session.OnlineSeconds)
+	}
+}
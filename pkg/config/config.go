@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+var ErrNoKeySpecified = errors.New("no secret key was specified")
+
+// Credentials and connection settings used to query a remote API.
+type Module struct {
+	Key       string                `yaml:"key"`
+	KeyFile   string                `yaml:"key_file"`
+	TLSConfig config_util.TLSConfig `yaml:"tls_config"`
+}
+
+// A dedicated server whose metrics are exposed on the metrics endpoint.
+type Server struct {
+	Module `yaml:",inline"`
+
+	// Identifies the server in the exposed metrics and on the other
+	// endpoints of the exporter.
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+
+	// The collectors enabled for this server. All collectors enabled on the
+	// command line are used when empty.
+	Collectors []string `yaml:"collectors"`
+
+	// Labels attached to every metric of this server, besides the server
+	// label holding the name of the server.
+	Labels map[string]string `yaml:"labels"`
+}
+
+type Config struct {
+	Modules map[string]Module `yaml:"modules"`
+	Servers []Server          `yaml:"servers"`
+}
+
+// Returns the labels attached to the metrics of the server.
+func (s *Server) MetricLabels() model.LabelSet {
+	labels := model.LabelSet{"server": model.LabelValue(s.Name)}
+	for name, value := range s.Labels {
+		labels[model.LabelName(name)] = model.LabelValue(value)
+	}
+	return labels
+}
+
+// Returns the sorted, comma-separated names of the given labels.
+func joinLabelNames(labels model.LabelSet) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Check the module for errors.
+func (m *Module) Validate() error {
+	if m.Key == "" && m.KeyFile == "" {
+		return ErrNoKeySpecified
+	}
+	if m.Key != "" && m.KeyFile != "" {
+		return errors.New("at most one of key and key_file may be specified")
+	}
+	return m.TLSConfig.Validate()
+}
+
+// Check the server for errors.
+func (s *Server) Validate() error {
+	if s.URL == "" {
+		return errors.New("url is required")
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url %q: scheme must be http or https", s.URL)
+	}
+
+	for name := range s.Labels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if name == "server" {
+			return errors.New("the server label is set to the name of the server and cannot be overridden")
+		}
+	}
+
+	return s.Module.Validate()
+}
+
+// Check the configuration for errors.
+func (c *Config) Validate() error {
+	for name, module := range c.Modules {
+		if err := module.Validate(); err != nil {
+			return fmt.Errorf("module %q: %w", name, err)
+		}
+	}
+
+	names := make(map[string]bool)
+	labelSets := make(map[string]string)
+	var labelNames string
+	for i := range c.Servers {
+		server := &c.Servers[i]
+		if server.Name == "" {
+			return fmt.Errorf("server %d: name is required", i)
+		}
+		if names[server.Name] {
+			return fmt.Errorf("server %q: duplicate name", server.Name)
+		}
+		names[server.Name] = true
+
+		if err := server.Validate(); err != nil {
+			return fmt.Errorf("server %q: %w", server.Name, err)
+		}
+
+		// The metrics of all servers are exposed together, so they must
+		// carry the same label names with distinct values.
+		labels := server.MetricLabels()
+		if i == 0 {
+			labelNames = joinLabelNames(labels)
+		} else if joinLabelNames(labels) != labelNames {
+			return fmt.Errorf("server %q: all servers must define the same labels, got [%s] instead of [%s]", server.Name, joinLabelNames(labels), labelNames)
+		}
+		if other, ok := labelSets[labels.String()]; ok {
+			return fmt.Errorf("server %q: labels %s are already used by server %q", server.Name, labels, other)
+		}
+		labelSets[labels.String()] = server.Name
+	}
+
+	return nil
+}
+
+// Parse and validate the configuration in the given YAML document. Unknown
+// fields are rejected.
+func Load(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read, parse and validate the configuration file at the given path.
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return cfg, nil
+}
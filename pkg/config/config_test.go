@@ -0,0 +1,108 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load([]byte(`
+modules:
+  fleet:
+    key_file: /etc/fleet.key
+servers:
+  - name: alpha
+    url: https://alpha.example.com:8080
+    key: c2VjcmV0
+    labels:
+      env: prod
+  - name: beta
+    url: http://beta.example.com:8080
+    key_file: /etc/beta.key
+    labels:
+      env: staging
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Servers) != 2 || len(cfg.Modules) != 1 {
+		t.Fatalf("unexpected configuration %+v", cfg)
+	}
+	if labels := cfg.Servers[1].MetricLabels().String(); labels != `{env="staging", server="beta"}` {
+		t.Errorf("unexpected labels %s", labels)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "unknown field",
+			config: "servers:\n  - name: alpha\n    url: http://alpha\n    key: c2VjcmV0\n    port: 8080\n",
+			err:    "field port not found",
+		},
+		{
+			name:   "module without key",
+			config: "modules:\n  fleet: {}\n",
+			err:    `module "fleet": no secret key was specified`,
+		},
+		{
+			name:   "module with key and key file",
+			config: "modules:\n  fleet:\n    key: c2VjcmV0\n    key_file: /etc/fleet.key\n",
+			err:    `module "fleet": at most one of key and key_file may be specified`,
+		},
+		{
+			name:   "server without key",
+			config: "servers:\n  - name: alpha\n    url: http://alpha\n",
+			err:    `server "alpha": no secret key was specified`,
+		},
+		{
+			name:   "server without name",
+			config: "servers:\n  - url: http://alpha\n    key: c2VjcmV0\n",
+			err:    "server 0: name is required",
+		},
+		{
+			name:   "duplicate server",
+			config: "servers:\n  - name: alpha\n    url: http://alpha\n    key: c2VjcmV0\n  - name: alpha\n    url: http://beta\n    key: c2VjcmV0\n",
+			err:    `server "alpha": duplicate name`,
+		},
+		{
+			name:   "server without url",
+			config: "servers:\n  - name: alpha\n    key: c2VjcmV0\n",
+			err:    `server "alpha": url is required`,
+		},
+		{
+			name:   "invalid url scheme",
+			config: "servers:\n  - name: alpha\n    url: ftp://alpha\n    key: c2VjcmV0\n",
+			err:    "scheme must be http or https",
+		},
+		{
+			name:   "invalid label name",
+			config: "servers:\n  - name: alpha\n    url: http://alpha\n    key: c2VjcmV0\n    labels:\n      my-env: prod\n",
+			err:    `invalid label name "my-env"`,
+		},
+		{
+			name:   "server label",
+			config: "servers:\n  - name: alpha\n    url: http://alpha\n    key: c2VjcmV0\n    labels:\n      server: beta\n",
+			err:    "the server label is set to the name of the server",
+		},
+		{
+			name:   "different label names",
+			config: "servers:\n  - name: alpha\n    url: http://alpha\n    key: c2VjcmV0\n    labels:\n      env: prod\n  - name: beta\n    url: http://beta\n    key: c2VjcmV0\n",
+			err:    `server "beta": all servers must define the same labels`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load([]byte(tc.config))
+			if err == nil {
+				t.Fatalf("expected an error containing %q", tc.err)
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %q", tc.err, err)
+			}
+		})
+	}
+}
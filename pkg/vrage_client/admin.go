@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Perform a write request against the API. The body, when not nil, is encoded
+// as JSON.
+func doBasicWrite(ctx context.Context, c *VRageClient, path string, method string, body interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	_, err := c.RequestWithBody(ctx, path, method, data)
+	return err
+}
+
+// Kick the player with the given Steam ID from the server.
+func (c *VRageClient) KickPlayer(ctx context.Context, steamId uint64) error {
+	path := fmt.Sprintf("/v1/admin/kickedPlayers/%d", steamId)
+	return doBasicWrite(ctx, c, path, "POST", nil)
+}
+
+// Ban the player with the given Steam ID from the server.
+func (c *VRageClient) BanPlayer(ctx context.Context, steamId uint64) error {
+	path := fmt.Sprintf("/v1/admin/bannedPlayers/%d", steamId)
+	return doBasicWrite(ctx, c, path, "POST", nil)
+}
+
+// Lift the ban of the player with the given Steam ID.
+func (c *VRageClient) UnbanPlayer(ctx context.Context, steamId uint64) error {
+	path := fmt.Sprintf("/v1/admin/bannedPlayers/%d", steamId)
+	return doBasicWrite(ctx, c, path, "DELETE", nil)
+}
+
+// Promote the player with the given Steam ID by one level.
+func (c *VRageClient) PromotePlayer(ctx context.Context, steamId uint64) error {
+	path := fmt.Sprintf("/v1/admin/promotedPlayers/%d", steamId)
+	return doBasicWrite(ctx, c, path, "POST", nil)
+}
+
+// Demote the player with the given Steam ID by one level.
+func (c *VRageClient) DemotePlayer(ctx context.Context, steamId uint64) error {
+	path := fmt.Sprintf("/v1/admin/promotedPlayers/%d", steamId)
+	return doBasicWrite(ctx, c, path, "DELETE", nil)
+}
+
+// Save the world.
+func (c *VRageClient) SaveWorld(ctx context.Context) error {
+	return doBasicWrite(ctx, c, "/v1/session", "PATCH", nil)
+}
+
+// Stop the dedicated server.
+func (c *VRageClient) StopServer(ctx context.Context) error {
+	return doBasicWrite(ctx, c, "/v1/server", "DELETE", nil)
+}
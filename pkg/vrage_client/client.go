@@ -1,6 +1,8 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha1"
@@ -28,6 +30,8 @@ type VRageClient struct {
 	key        []byte
 	httpClient *http.Client
 	logger     *log.Logger
+	retry      RetryPolicy
+	breaker    *circuitBreaker
 }
 
 // Decode and return the secret key.
@@ -71,17 +75,26 @@ func GetNonce() (int, error) {
 //
 // Returns an error if the key is not able to be loaded from the specified key file.
 func NewVRageClient(api string, keyFile string, key string, sslVerify bool, logger *log.Logger) (*VRageClient, error) {
+	return NewVRageClientWithTLSConfig(api, keyFile, key, &tls.Config{InsecureSkipVerify: !sslVerify}, logger)
+}
+
+// Create and return a new VRage client using the given TLS configuration to
+// connect to the remote API.
+//
+// Returns an error if the key is not able to be loaded from the specified key file.
+func NewVRageClientWithTLSConfig(api string, keyFile string, key string, tlsConfig *tls.Config, logger *log.Logger) (*VRageClient, error) {
 	var err error
 	if keyFile == "" && key == "" {
 		return nil, ErrNoKeySpecified
 	}
 
 	c := VRageClient{
-		api:    api,
-		logger: logger,
+		api:     api,
+		logger:  logger,
+		breaker: &circuitBreaker{},
 		httpClient: &http.Client{
 			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: !sslVerify},
+				TLSClientConfig: tlsConfig,
 			},
 		},
 	}
@@ -101,6 +114,36 @@ func NewVRageClient(api string, keyFile string, key string, sslVerify bool, logg
 	return &c, nil
 }
 
+// Replace the transport used to reach the remote API with the one returned by
+// the given function, which receives the current transport.
+func (c *VRageClient) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.httpClient.Transport = wrap(c.httpClient.Transport)
+}
+
+// Close the idle connections to the remote API, once the client is no longer
+// used.
+func (c *VRageClient) CloseIdleConnections() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// Retry the idempotent requests according to the given policy.
+func (c *VRageClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// Stop sending requests for the given cooldown after the given number of
+// consecutive requests failed because the server could not be reached or
+// responded with a 5xx status code. The circuit breaker is disabled when the
+// threshold is 0.
+func (c *VRageClient) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	c.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Returns the state of the circuit breaker.
+func (c *VRageClient) CircuitState() CircuitState {
+	return c.breaker.currentState()
+}
+
 // Loads and decodes the secret key from disk.
 func (c *VRageClient) loadKey() error {
 	data, err := os.ReadFile(c.keyFile)
@@ -145,7 +188,49 @@ func (c *VRageClient) getHeaders(url string) (http.Header, error) {
 }
 
 // Make a request to the remote API and return the response data.
-func (c *VRageClient) Request(path string, method string) ([]byte, error) {
+func (c *VRageClient) Request(ctx context.Context, path string, method string) ([]byte, error) {
+	return c.RequestWithBody(ctx, path, method, nil)
+}
+
+// Make a request with the given JSON body to the remote API and return the
+// response data. A nil body sends the request without one. GET requests are
+// retried according to the retry policy of the client.
+func (c *VRageClient) RequestWithBody(ctx context.Context, path string, method string, body []byte) ([]byte, error) {
+	if !c.breaker.allow(time.Now()) {
+		return nil, ErrCircuitOpen
+	}
+
+	attempts := 1
+	if method == http.MethodGet {
+		attempts += c.retry.MaxRetries
+	}
+
+	var respBody []byte
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			backoff := c.retry.backoff(attempt - 1)
+			level.Debug(*c.logger).Log("msg", "Retrying request", "path", path, "backoff", backoff, "err", err)
+			// A cancelled request fails below with the error of the
+			// context, without being retried.
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+			}
+		}
+
+		respBody, err = c.doRequest(ctx, path, method, body)
+		if !isServerFailure(ctx, err) {
+			break
+		}
+	}
+
+	c.breaker.record(ctx, err, time.Now())
+	return respBody, err
+}
+
+// Make a single request to the remote API and return the response data.
+func (c *VRageClient) doRequest(ctx context.Context, path string, method string, body []byte) ([]byte, error) {
 	fullPath := fmt.Sprintf("%s%s", base_path, path)
 	fullUrl := fmt.Sprintf("%s%s", c.api, fullPath)
 
@@ -165,7 +250,13 @@ func (c *VRageClient) Request(path string, method string) ([]byte, error) {
 		fmt.Sprintf("%v", headers),
 	)
 
-	req, err := http.NewRequest(method, fullUrl, nil)
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+		headers.Add("Content-Type", "application/json")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fullUrl, reqBody)
 	if err != nil {
 		level.Error(*c.logger).Log("msg", "Failed to create new request", "err", err)
 		return nil, err
@@ -188,22 +279,22 @@ func (c *VRageClient) Request(path string, method string) ([]byte, error) {
 		resp.StatusCode,
 	)
 
-	if resp.StatusCode != 200 {
-		return nil, ErrNon2XXResponse
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		level.Error(*c.logger).Log("msg", "Failed to read response body", "err", err)
 		return nil, err
 	}
 
-	return body, nil
+	return respBody, nil
 }
 
 // Perform a GET request against the API and populate the provided response object.
-func doBasicGet[R Response](c *VRageClient, path string, resp *R) error {
-	body, err := c.Request(path, "GET")
+func doBasicGet[R Response](ctx context.Context, c *VRageClient, path string, resp *R) error {
+	body, err := c.Request(ctx, path, "GET")
 	if err != nil {
 		return err
 	}
@@ -216,10 +307,10 @@ func doBasicGet[R Response](c *VRageClient, path string, resp *R) error {
 }
 
 // Pings the server and returns true if we received a pong, false otherwise.
-func (c *VRageClient) Ping() (bool, error) {
+func (c *VRageClient) Ping(ctx context.Context) (bool, error) {
 	path := "/v1/server/ping"
 	resp := PingResponse{}
-	if err := doBasicGet(c, path, &resp); err != nil {
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
 		return false, err
 	}
 
@@ -227,10 +318,10 @@ func (c *VRageClient) Ping() (bool, error) {
 }
 
 // Collect and return the server details.
-func (c *VRageClient) GetServerDetails() (*ServerResponse, error) {
+func (c *VRageClient) GetServerDetails(ctx context.Context) (*ServerResponse, error) {
 	path := "/v1/server"
 	resp := ServerResponse{}
-	if err := doBasicGet(c, path, &resp); err != nil {
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
 		return &resp, err
 	}
 
@@ -238,10 +329,10 @@ func (c *VRageClient) GetServerDetails() (*ServerResponse, error) {
 }
 
 // Retrieve the list of planets from the server.
-func (c *VRageClient) GetPlanets() (*PlanetResponse, error) {
+func (c *VRageClient) GetPlanets(ctx context.Context) (*PlanetResponse, error) {
 	path := "/v1/session/planets"
 	resp := PlanetResponse{}
-	if err := doBasicGet(c, path, &resp); err != nil {
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
 		return &resp, err
 	}
 
@@ -249,10 +340,10 @@ func (c *VRageClient) GetPlanets() (*PlanetResponse, error) {
 }
 
 // Retrieve the list of asteroids from the server.
-func (c *VRageClient) GetAsteroids() (*AsteroidResponse, error) {
+func (c *VRageClient) GetAsteroids(ctx context.Context) (*AsteroidResponse, error) {
 	path := "/v1/session/asteroids"
 	resp := AsteroidResponse{}
-	if err := doBasicGet(c, path, &resp); err != nil {
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
 		return &resp, err
 	}
 
@@ -260,10 +351,21 @@ func (c *VRageClient) GetAsteroids() (*AsteroidResponse, error) {
 }
 
 // Retrieve the list of grids from the server.
-func (c *VRageClient) GetGrids() (*GridResponse, error) {
+func (c *VRageClient) GetGrids(ctx context.Context) (*GridResponse, error) {
 	path := "/v1/session/grids"
 	resp := GridResponse{}
-	if err := doBasicGet(c, path, &resp); err != nil {
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
+		return &resp, err
+	}
+
+	return &resp, nil
+}
+
+// Retrieve the list of players currently connected to the server.
+func (c *VRageClient) GetPlayers(ctx context.Context) (*PlayersResponse, error) {
+	path := "/v1/session/players"
+	resp := PlayersResponse{}
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
 		return &resp, err
 	}
 
@@ -271,10 +373,10 @@ func (c *VRageClient) GetGrids() (*GridResponse, error) {
 }
 
 // Retrieve the list of banned players.
-func (c *VRageClient) GetBannedPlayers() (*BannedPlayersResponse, error) {
+func (c *VRageClient) GetBannedPlayers(ctx context.Context) (*BannedPlayersResponse, error) {
 	path := "/v1/admin/bannedPlayers"
 	resp := BannedPlayersResponse{}
-	if err := doBasicGet(c, path, &resp); err != nil {
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
 		return &resp, err
 	}
 
@@ -282,10 +384,10 @@ func (c *VRageClient) GetBannedPlayers() (*BannedPlayersResponse, error) {
 }
 
 // Retrieve the list of kicked players.
-func (c *VRageClient) GetKickedPlayers() (*KickedPlayersResponse, error) {
+func (c *VRageClient) GetKickedPlayers(ctx context.Context) (*KickedPlayersResponse, error) {
 	path := "/v1/admin/kickedPlayers"
 	resp := KickedPlayersResponse{}
-	if err := doBasicGet(c, path, &resp); err != nil {
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
 		return &resp, err
 	}
 
@@ -293,10 +395,37 @@ func (c *VRageClient) GetKickedPlayers() (*KickedPlayersResponse, error) {
 }
 
 // Retrieve the list of cheaters.
-func (c *VRageClient) GetCheaters() (*CheatersResponse, error) {
+func (c *VRageClient) GetCheaters(ctx context.Context) (*CheatersResponse, error) {
 	path := "/v1/admin/cheaters"
 	resp := CheatersResponse{}
-	if err := doBasicGet(c, path, &resp); err != nil {
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
+		return &resp, err
+	}
+
+	return &resp, nil
+}
+
+// Retrieve the list of characters.
+func (c *VRageClient) GetCharacters(ctx context.Context) (*CharactersResponse, error) {
+	path := "/v1/session/characters"
+	resp := CharactersResponse{}
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
+		return &resp, err
+	}
+
+	return &resp, nil
+}
+
+// Retrieve the chat messages sent after the given timestamp. The timestamp is
+// expressed in the same units as ChatMessageResponseData.Timestamp; pass 0 to
+// retrieve the full chat history held by the server.
+func (c *VRageClient) GetChatMessages(ctx context.Context, since int64) (*ChatMessagesResponse, error) {
+	path := "/v1/session/chat"
+	if since > 0 {
+		path = fmt.Sprintf("%s?Date=%d", path, since)
+	}
+	resp := ChatMessagesResponse{}
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
 		return &resp, err
 	}
 
@@ -304,15 +433,12 @@ func (c *VRageClient) GetCheaters() (*CheatersResponse, error) {
 }
 
 // Retrieve the list of floating objects.
-func (c *VRageClient) GetFloatingObjects() (*FloatingObjectsResponse, error) {
-	return nil, ErrNotImplemented
-	/*
-		path := "/v1/session/floatingObjects"
-		resp := FloatingObjectsResponse{}
-		if err := doBasicGet(c, path, &resp); err != nil {
-			return &resp, err
-		}
+func (c *VRageClient) GetFloatingObjects(ctx context.Context) (*FloatingObjectsResponse, error) {
+	path := "/v1/session/floatingObjects"
+	resp := FloatingObjectsResponse{}
+	if err := doBasicGet(ctx, c, path, &resp); err != nil {
+		return &resp, err
+	}
 
-		return &resp, nil
-	*/
+	return &resp, nil
 }
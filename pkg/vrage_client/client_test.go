@@ -0,0 +1,348 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+	"github.com/thelande/space-engineers-exporter/pkg/vrage_client/vragetest"
+)
+
+func newTestClient(t *testing.T) (*vragetest.Server, *vrage_client.VRageClient) {
+	t.Helper()
+
+	srv := vragetest.NewServer()
+	t.Cleanup(srv.Close)
+
+	client, err := srv.NewClient(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, client
+}
+
+func TestPing(t *testing.T) {
+	_, client := newTestClient(t)
+
+	pong, err := client.Ping(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pong {
+		t.Error("expected a pong")
+	}
+}
+
+func TestGetGrids(t *testing.T) {
+	srv, client := newTestClient(t)
+	srv.MustSetData("GET", "/v1/session/grids", map[string]interface{}{
+		"Grids": []vrage_client.GridResponseData{
+			{DisplayName: "Ship", EntityId: 10, BlocksCount: 5, PCU: 50},
+		},
+	})
+
+	resp, err := client.GetGrids(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data.Grids) != 1 {
+		t.Fatalf("expected 1 grid, got %d", len(resp.Data.Grids))
+	}
+	if grid := resp.Data.Grids[0]; grid.DisplayName != "Ship" || grid.PCU != 50 {
+		t.Errorf("unexpected grid %+v", grid)
+	}
+}
+
+func TestGetChatMessagesSignsQuery(t *testing.T) {
+	srv, client := newTestClient(t)
+	srv.SetFunc("GET", "/v1/session/chat", func(r *http.Request) vragetest.Fixture {
+		fixture, _ := vragetest.NewFixture("GET", "/v1/session/chat", map[string]interface{}{
+			"Messages": []vrage_client.ChatMessageResponseData{
+				{DisplayName: r.URL.Query().Get("Date"), Timestamp: 2000},
+			},
+		})
+		return fixture
+	})
+
+	resp, err := client.GetChatMessages(context.Background(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data.Messages) != 1 || resp.Data.Messages[0].DisplayName != "1000" {
+		t.Errorf("unexpected messages %+v", resp.Data.Messages)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	srv := vragetest.NewServer()
+	defer srv.Close()
+
+	logger := log.NewNopLogger()
+	client, err := vrage_client.NewVRageClient(srv.URL, "", "d3Jvbmcga2V5", true, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Ping(context.Background())
+	var statusErr *vrage_client.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a 403 error, got %v", err)
+	}
+	if srv.Count("GET", "/v1/server/ping") != 0 {
+		t.Error("unauthenticated request was counted")
+	}
+}
+
+func TestFaults(t *testing.T) {
+	srv, client := newTestClient(t)
+
+	srv.SetFault("GET", "/v1/server", vragetest.Fault{StatusCode: http.StatusInternalServerError})
+	if _, err := client.GetServerDetails(context.Background()); !errors.Is(err, vrage_client.ErrNon2XXResponse) {
+		t.Errorf("expected a status error, got %v", err)
+	}
+
+	srv.SetFault("GET", "/v1/server", vragetest.Fault{MalformedJSON: true})
+	if _, err := client.GetServerDetails(context.Background()); err == nil {
+		t.Error("expected a decoding error")
+	}
+
+	srv.SetFault("GET", "/v1/server", vragetest.Fault{Latency: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetServerDetails(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	srv.SetFault("GET", "/v1/server", vragetest.Fault{})
+	if _, err := client.GetServerDetails(context.Background()); err != nil {
+		t.Errorf("unexpected error after removing the fault: %v", err)
+	}
+	if n := srv.Count("GET", "/v1/server"); n != 4 {
+		t.Errorf("expected 4 requests, got %d", n)
+	}
+}
+
+func TestDeleteMissingGrid(t *testing.T) {
+	srv, client := newTestClient(t)
+
+	err := client.DeleteGrid(context.Background(), 42)
+	if !errors.Is(err, vrage_client.ErrEntityNotFound) {
+		t.Errorf("expected an entity not found error, got %v", err)
+	}
+
+	srv.MustSetData("DELETE", "/v1/session/grids/42", nil)
+	if err := client.DeleteGrid(context.Background(), 42); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGridActions(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		action func(c *vrage_client.VRageClient, entityId int64) error
+		check  func(grids []vrage_client.GridResponseData) bool
+	}{
+		{
+			name:   "delete",
+			method: "DELETE",
+			path:   "/v1/session/grids/42",
+			action: func(c *vrage_client.VRageClient, entityId int64) error {
+				return c.DeleteGrid(context.Background(), entityId)
+			},
+			check: func(grids []vrage_client.GridResponseData) bool { return len(grids) == 0 },
+		},
+		{
+			name:   "stop",
+			method: "PATCH",
+			path:   "/v1/session/grids/42",
+			action: func(c *vrage_client.VRageClient, entityId int64) error {
+				return c.StopGrid(context.Background(), entityId)
+			},
+			check: func(grids []vrage_client.GridResponseData) bool {
+				return len(grids) == 1 && grids[0].LinearSpeed == 0
+			},
+		},
+		{
+			name:   "power off",
+			method: "DELETE",
+			path:   "/v1/session/poweredGrids/42",
+			action: func(c *vrage_client.VRageClient, entityId int64) error {
+				return c.PowerOffGrid(context.Background(), entityId)
+			},
+			check: func(grids []vrage_client.GridResponseData) bool {
+				return len(grids) == 1 && !grids[0].IsPowered
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, client := newTestClient(t)
+			srv.MustSetData("GET", "/v1/session/grids", map[string]interface{}{
+				"Grids": []vrage_client.GridResponseData{{EntityId: 42, DisplayName: "Ship", IsPowered: true, LinearSpeed: 20}},
+			})
+
+			var notFound *vrage_client.EntityNotFoundError
+			if err := tc.action(client, 7); !errors.As(err, &notFound) || notFound.EntityId != 7 {
+				t.Errorf("expected an entity not found error for entity 7, got %v", err)
+			}
+
+			if err := tc.action(client, 42); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n := srv.Count(tc.method, tc.path); n != 1 {
+				t.Errorf("expected 1 request to %s %s, got %d", tc.method, tc.path, n)
+			}
+
+			resp, err := client.GetGrids(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tc.check(resp.Data.Grids) {
+				t.Errorf("unexpected grids after the action: %+v", resp.Data.Grids)
+			}
+		})
+	}
+}
+
+// Returns a fixture function failing with the given status code for the first
+// given number of requests, and serving the given fixture afterwards.
+func failingFixture(failures int, status int, fixture vragetest.Fixture) vragetest.FixtureFunc {
+	var mu sync.Mutex
+	return func(*http.Request) vragetest.Fixture {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failures > 0 {
+			failures--
+			return vragetest.Fixture{StatusCode: status}
+		}
+		return fixture
+	}
+}
+
+func TestRetry(t *testing.T) {
+	srv, client := newTestClient(t)
+	client.SetRetryPolicy(vrage_client.RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+
+	fixture, err := vragetest.NewFixture("GET", "/v1/server", vrage_client.ServerResponseData{ServerName: "retried"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetFunc("GET", "/v1/server", failingFixture(2, http.StatusServiceUnavailable, fixture))
+
+	resp, err := client.GetServerDetails(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.ServerName != "retried" {
+		t.Errorf("unexpected server name %q", resp.Data.ServerName)
+	}
+	if n := srv.Count("GET", "/v1/server"); n != 3 {
+		t.Errorf("expected 3 requests, got %d", n)
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	srv, client := newTestClient(t)
+	client.SetRetryPolicy(vrage_client.RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+
+	// Client errors are not retried.
+	srv.SetFault("GET", "/v1/server", vragetest.Fault{StatusCode: http.StatusForbidden})
+	if _, err := client.GetServerDetails(context.Background()); err == nil {
+		t.Error("expected an error")
+	}
+	if n := srv.Count("GET", "/v1/server"); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
+
+	// Writes are not idempotent, so they are not retried.
+	srv.SetFault("PATCH", "/v1/session", vragetest.Fault{StatusCode: http.StatusInternalServerError})
+	if err := client.SaveWorld(context.Background()); err == nil {
+		t.Error("expected an error")
+	}
+	if n := srv.Count("PATCH", "/v1/session"); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	srv, client := newTestClient(t)
+	client.SetCircuitBreaker(2, 50*time.Millisecond)
+
+	srv.SetFault("GET", "/v1/server/ping", vragetest.Fault{StatusCode: http.StatusInternalServerError})
+	for i := 0; i < 2; i++ {
+		if _, err := client.Ping(context.Background()); !errors.Is(err, vrage_client.ErrNon2XXResponse) {
+			t.Fatalf("expected a status error, got %v", err)
+		}
+	}
+	if state := client.CircuitState(); state != vrage_client.CircuitOpen {
+		t.Fatalf("expected an open circuit, got %v", state)
+	}
+
+	if _, err := client.Ping(context.Background()); !errors.Is(err, vrage_client.ErrCircuitOpen) {
+		t.Errorf("expected an open circuit error, got %v", err)
+	}
+	if n := srv.Count("GET", "/v1/server/ping"); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+
+	// Once the cooldown elapsed, a successful request closes the circuit.
+	time.Sleep(60 * time.Millisecond)
+	srv.SetFault("GET", "/v1/server/ping", vragetest.Fault{})
+	if _, err := client.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if state := client.CircuitState(); state != vrage_client.CircuitClosed {
+		t.Errorf("expected a closed circuit, got %v", state)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv, client := newTestClient(t)
+
+	dir := t.TempDir()
+	recorder, err := vrage_client.NewRecorder(dir, http.DefaultTransport, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.WrapTransport(func(http.RoundTripper) http.RoundTripper { return recorder })
+
+	for _, name := range []string{"first", "second"} {
+		srv.MustSetData("GET", "/v1/server", vrage_client.ServerResponseData{ServerName: name})
+		if _, err := client.GetServerDetails(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fixtures, err := vrage_client.LoadFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 2 {
+		t.Fatalf("expected 2 fixtures, got %d", len(fixtures))
+	}
+
+	srv.Close()
+	client.WrapTransport(func(http.RoundTripper) http.RoundTripper { return vrage_client.NewReplayTransport(fixtures) })
+
+	for _, want := range []string{"first", "second", "second"} {
+		resp, err := client.GetServerDetails(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Data.ServerName != want {
+			t.Errorf("expected server %q, got %q", want, resp.Data.ServerName)
+		}
+	}
+
+	// Requests that were not recorded are answered with 404.
+	if _, err := client.GetGrids(context.Background()); !errors.Is(err, vrage_client.ErrNon2XXResponse) {
+		t.Errorf("expected a status error, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Perform a write request against an entity of the session. A 404 response is
+// reported as an EntityNotFoundError.
+func doEntityWrite(ctx context.Context, c *VRageClient, path string, method string, entityId int64) error {
+	err := doBasicWrite(ctx, c, path, method, nil)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return &EntityNotFoundError{EntityId: entityId}
+	}
+
+	return err
+}
+
+// Delete the grid with the given entity ID.
+func (c *VRageClient) DeleteGrid(ctx context.Context, entityId int64) error {
+	path := fmt.Sprintf("/v1/session/grids/%d", entityId)
+	return doEntityWrite(ctx, c, path, "DELETE", entityId)
+}
+
+// Stop the grid with the given entity ID, cancelling its linear and angular
+// velocity.
+func (c *VRageClient) StopGrid(ctx context.Context, entityId int64) error {
+	path := fmt.Sprintf("/v1/session/grids/%d", entityId)
+	return doEntityWrite(ctx, c, path, "PATCH", entityId)
+}
+
+// Turn off the power of the grid with the given entity ID. DELETE and PATCH on
+// /v1/session/grids/{entityId} already delete and stop the grid, so the
+// dedicated server exposes this operation on the powered grids resource
+// instead, as called by the VRage Remote Client shipped with it.
+func (c *VRageClient) PowerOffGrid(ctx context.Context, entityId int64) error {
+	path := fmt.Sprintf("/v1/session/poweredGrids/%d", entityId)
+	return doEntityWrite(ctx, c, path, "DELETE", entityId)
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// A response of the remote API, as recorded by a Recorder. The path is
+// relative to the base path of the remote API, without the query string.
+type Fixture struct {
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	StatusCode int             `json:"status_code"`
+	Body       json.RawMessage `json:"body,omitempty"`
+}
+
+// Records the responses of the remote API as fixtures, one file per response,
+// in the order they are received.
+type Recorder struct {
+	dir    string
+	next   http.RoundTripper
+	logger log.Logger
+
+	mu  sync.Mutex
+	seq int
+}
+
+// Create and return a recorder writing the responses obtained with the given
+// transport to the given directory. The numbering of the fixtures continues
+// after the fixtures already in the directory.
+func NewRecorder(dir string, next http.RoundTripper, logger log.Logger) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	existing, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, next: next, logger: logger, seq: len(existing)}, nil
+}
+
+// Perform the request with the underlying transport and record the response.
+// Failing to record a response is logged and does not fail the request.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := Fixture{
+		Method:     req.Method,
+		Path:       strings.TrimPrefix(req.URL.Path, base_path),
+		StatusCode: resp.StatusCode,
+	}
+	// Error pages are not JSON, and are recorded without a body.
+	if json.Valid(body) {
+		fixture.Body = body
+	}
+	if err := r.write(fixture); err != nil {
+		level.Error(r.logger).Log("msg", "Failed to record response", "path", fixture.Path, "err", err)
+	}
+
+	return resp, nil
+}
+
+func (r *Recorder) write(fixture Fixture) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	name := fmt.Sprintf("%06d_%s%s.json", r.seq, fixture.Method, strings.ReplaceAll(fixture.Path, "/", "_"))
+	return os.WriteFile(filepath.Join(r.dir, name), data, 0o644)
+}
+
+// Read the fixtures in the given directory, in the order of their file names.
+func LoadFixtures(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var fixture Fixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// Serves recorded fixtures instead of querying the remote API.
+type replayTransport struct {
+	mu     sync.Mutex
+	routes map[string][]Fixture
+}
+
+// Returns a transport answering the requests with the given fixtures, in
+// order. Each request of a method and path is answered with the next fixture
+// of that method and path, and the last one is repeated once they are
+// exhausted. Requests without a fixture are answered with 404.
+func NewReplayTransport(fixtures []Fixture) http.RoundTripper {
+	t := &replayTransport{routes: make(map[string][]Fixture)}
+	for _, fixture := range fixtures {
+		key := fixture.Method + " " + fixture.Path
+		t.routes[key] = append(t.routes[key], fixture)
+	}
+	return t
+}
+
+// Returns the next fixture of the given method and path.
+func (t *replayTransport) next(method string, path string) (Fixture, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := method + " " + path
+	sequence := t.routes[key]
+	if len(sequence) == 0 {
+		return Fixture{}, false
+	}
+	if len(sequence) > 1 {
+		t.routes[key] = sequence[1:]
+	}
+	return sequence[0], true
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	fixture, ok := t.next(req.Method, strings.TrimPrefix(req.URL.Path, base_path))
+	status := fixture.StatusCode
+	if !ok {
+		status = http.StatusNotFound
+	} else if status == 0 {
+		status = http.StatusOK
+	}
+
+	header := http.Header{}
+	if len(fixture.Body) > 0 {
+		header.Set("Content-Type", "application/json")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(fixture.Body)),
+		ContentLength: int64(len(fixture.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Controls the retries of the idempotent requests that fail because the
+// server could not be reached or responded with a 5xx status code.
+type RetryPolicy struct {
+	// The number of retries after the first attempt. Requests are not
+	// retried when 0.
+	MaxRetries int
+	// The maximum delay before the first retry, doubled at each retry up to
+	// MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Returns the delay before the given retry, counted from 0.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	// MaxBackoff is shifted rather than InitialBackoff, which could
+	// overflow.
+	d := p.MaxBackoff
+	if retry < 32 && p.InitialBackoff < p.MaxBackoff>>retry {
+		d = p.InitialBackoff << retry
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Full jitter spreads the retries of the requests that failed together.
+	return rand.N(d)
+}
+
+// Returns true if the request failed in a way that another attempt may fix:
+// the server could not be reached or responded with a 5xx status code.
+func isServerFailure(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+
+	// The client wraps every error in a *url.Error, which is a net.Error
+	// itself, including the ones another attempt would not fix such as an
+	// invalid URL or an untrusted certificate.
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+	err = urlErr.Err
+
+	// A TLS alert means the server rejected the connection.
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// The state of the circuit breaker of a client.
+type CircuitState int
+
+const (
+	// Requests are sent to the server.
+	CircuitClosed CircuitState = iota
+	// A single request is sent to the server to check whether it recovered.
+	CircuitHalfOpen
+	// Requests fail immediately with ErrCircuitOpen.
+	CircuitOpen
+)
+
+// Stops sending requests to a server that keeps failing, typically because it
+// is restarting, and lets a request through once in a while to check whether
+// it recovered.
+type circuitBreaker struct {
+	mu sync.Mutex
+	// The number of consecutive failed requests opening the circuit. The
+	// circuit never opens when 0.
+	threshold int
+	// The time during which the circuit stays open.
+	cooldown time.Duration
+
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// A request is checking whether the server recovered.
+	probing bool
+}
+
+// Returns true if a request may be sent to the server.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// Record the outcome of a request allowed by the circuit breaker. Cancelled
+// requests tell nothing about the server and are not counted.
+func (b *circuitBreaker) record(ctx context.Context, err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return
+	}
+	if ctx.Err() != nil {
+		b.probing = false
+		return
+	}
+
+	if !isServerFailure(ctx, err) {
+		b.state = CircuitClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = now
+		b.probing = false
+	}
+}
+
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
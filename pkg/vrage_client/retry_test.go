@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	for retry, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if d := p.backoff(retry); d < 0 || d >= max {
+			t.Errorf("retry %d: expected a backoff in [0, %s), got %s", retry, max, d)
+		}
+	}
+
+	// Doubling the initial backoff would overflow.
+	p = RetryPolicy{InitialBackoff: time.Hour, MaxBackoff: math.MaxInt64}
+	for retry := 0; retry < 40; retry++ {
+		if d := p.backoff(retry); d < 0 {
+			t.Fatalf("retry %d: negative backoff %s", retry, d)
+		}
+	}
+	// The jitter makes a zero backoff very unlikely once the backoff is
+	// clamped to MaxBackoff.
+	zero := 0
+	for i := 0; i < 10; i++ {
+		if p.backoff(31) == 0 {
+			zero++
+		}
+	}
+	if zero > 1 {
+		t.Errorf("expected non-zero backoffs, got %d zero backoffs", zero)
+	}
+}
+
+func TestIsServerFailure(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://127.0.0.1:8080/vrageremote/v1/server", Err: err}
+	}
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"5xx", &StatusError{StatusCode: 503}, true},
+		{"4xx", &StatusError{StatusCode: 404}, false},
+		{"connection refused", urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), true},
+		{"connection reset", urlErr(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}), true},
+		{"eof", urlErr(io.EOF), true},
+		{"dns", urlErr(&net.DNSError{Err: "no such host", Name: "se.example.com"}), true},
+		{"untrusted certificate", urlErr(&x509.UnknownAuthorityError{}), false},
+		{"invalid url", urlErr(errors.New("unsupported protocol scheme \"ftp\"")), false},
+		{"tls alert", urlErr(&net.OpError{Op: "remote error", Err: errors.New("tls: bad certificate")}), false},
+		{"decoding", errors.New("invalid character"), false},
+	} {
+		if got := isServerFailure(context.Background(), tc.err); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if isServerFailure(ctx, &StatusError{StatusCode: 503}) {
+		t.Error("expected a cancelled request not to be a server failure")
+	}
+}
@@ -1,13 +1,46 @@
 package client
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrNoKeySpecified = errors.New("no secret key was specified")
 	ErrNon2XXResponse = errors.New("received non 2XX status code")
 	ErrNotImplemented = errors.New("not implemented")
+	ErrEntityNotFound = errors.New("entity not found")
+	ErrCircuitOpen    = errors.New("circuit breaker is open")
 )
 
+// Returned when the remote API responds with a non 2XX status code. Matches
+// ErrNon2XXResponse with errors.Is.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %d", ErrNon2XXResponse, e.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	return ErrNon2XXResponse
+}
+
+// Returned when an operation targets an entity that no longer exists on the
+// server. Matches ErrEntityNotFound with errors.Is.
+type EntityNotFoundError struct {
+	EntityId int64
+}
+
+func (e *EntityNotFoundError) Error() string {
+	return fmt.Sprintf("%s: %d", ErrEntityNotFound, e.EntityId)
+}
+
+func (e *EntityNotFoundError) Unwrap() error {
+	return ErrEntityNotFound
+}
+
 type BaseResponse struct {
 	Meta struct {
 		ApiVersion string  `json:"apiVersion"`
@@ -116,6 +149,22 @@ type KickedPlayersResponse struct {
 	} `json:"data"`
 }
 
+type OnlinePlayerResponseData struct {
+	SteamID      uint64 `json:"SteamID"`
+	DisplayName  string `json:"DisplayName"`
+	FactionName  string `json:"FactionName"`
+	FactionTag   string `json:"FactionTag"`
+	PromoteLevel int    `json:"PromoteLevel"`
+	Ping         int    `json:"Ping"`
+}
+
+type PlayersResponse struct {
+	BaseResponse
+	Data struct {
+		Players []OnlinePlayerResponseData `json:"Players"`
+	} `json:"data"`
+}
+
 type CheaterResponseData struct {
 	Explanation    string `json:"Explanation"`
 	Id             int    `json:"Id"`
@@ -131,10 +180,49 @@ type CheatersResponse struct {
 	} `json:"data"`
 }
 
+type CharacterResponseData struct {
+	DisplayName string         `json:"DisplayName"`
+	EntityId    int64          `json:"EntityId"`
+	Mass        float64        `json:"Mass"`
+	Position    EntityPosition `json:"Position"`
+	LinearSpeed float64        `json:"LinearSpeed"`
+}
+
+type CharactersResponse struct {
+	BaseResponse
+	Data struct {
+		Characters []CharacterResponseData `json:"Characters"`
+	} `json:"data"`
+}
+
+type ChatMessageResponseData struct {
+	SteamID     uint64 `json:"SteamID"`
+	DisplayName string `json:"DisplayName"`
+	Content     string `json:"Content"`
+	Timestamp   int64  `json:"Timestamp"`
+}
+
+type ChatMessagesResponse struct {
+	BaseResponse
+	Data struct {
+		Messages []ChatMessageResponseData `json:"Messages"`
+	} `json:"data"`
+}
+
+type FloatingObjectResponseData struct {
+	DisplayName      string         `json:"DisplayName"`
+	EntityId         int64          `json:"EntityId"`
+	Kind             string         `json:"Kind"`
+	Mass             float64        `json:"Mass"`
+	Position         EntityPosition `json:"Position"`
+	LinearSpeed      float64        `json:"LinearSpeed"`
+	DistanceToPlayer float64        `json:"DistanceToPlayer"`
+}
+
 type FloatingObjectsResponse struct {
 	BaseResponse
 	Data struct {
-		FloatingObjects []interface{} `json:"FloatingObjects"`
+		FloatingObjects []FloatingObjectResponseData `json:"FloatingObjects"`
 	} `json:"data"`
 }
 
@@ -145,8 +233,11 @@ type Response interface {
 		PlanetResponse |
 		AsteroidResponse |
 		GridResponse |
+		PlayersResponse |
 		BannedPlayersResponse |
 		KickedPlayersResponse |
 		CheatersResponse |
+		CharactersResponse |
+		ChatMessagesResponse |
 		FloatingObjectsResponse
 }
@@ -0,0 +1,429 @@
+// Package vragetest provides a fake VRage Remote API, to test the client and
+// the collectors without a dedicated server.
+package vragetest
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+// The prefix of the paths of the remote API.
+const BasePath = "/vrageremote"
+
+// The largest accepted difference between the Date header of a request and
+// the time it is received. The nonces are remembered for twice this duration,
+// so that a request cannot be replayed while its date is accepted.
+const MaxDateSkew = 5 * time.Minute
+
+// The response to a request. The fixtures are keyed by the method and the path
+// of the request, relative to BasePath and without the query string. The
+// fixtures recorded with vrage_client.Recorder can be served as is.
+type Fixture = vrage_client.Fixture
+
+// Computes the response to a request dynamically.
+type FixtureFunc func(r *http.Request) Fixture
+
+// A failure injected in the responses of the fake remote API.
+type Fault struct {
+	// Wait this long before responding, or until the request is cancelled.
+	Latency time.Duration
+	// Respond with this status code and no body instead of the fixture,
+	// when not 0.
+	StatusCode int
+	// Respond with a truncated JSON document instead of the fixture.
+	MalformedJSON bool
+}
+
+// Returns a fixture responding to the given request with the given data,
+// wrapped in the envelope used by the remote API.
+func NewFixture(method string, path string, data interface{}) (Fixture, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"data": data,
+		"meta": map[string]interface{}{
+			"apiVersion": "1.0",
+			"queryTime":  0.1,
+		},
+	})
+	if err != nil {
+		return Fixture{}, err
+	}
+	return Fixture{Method: method, Path: path, StatusCode: http.StatusOK, Body: body}, nil
+}
+
+// Returns the key of the fixtures and faults of the given request.
+func routeKey(method string, path string) string {
+	return method + " " + path
+}
+
+// The routes acting on the grid whose entity ID ends the path, by the prefix
+// of their key, with the change they make to the grid. The grid is removed
+// when the change is nil.
+var gridActions = map[string]func(grid *vrage_client.GridResponseData){
+	"DELETE /v1/session/grids/":        nil,
+	"PATCH /v1/session/grids/":         func(grid *vrage_client.GridResponseData) { grid.LinearSpeed = 0 },
+	"DELETE /v1/session/poweredGrids/": func(grid *vrage_client.GridResponseData) { grid.IsPowered = false },
+}
+
+// An http.Handler implementing the remote API. Requests are authenticated
+// like the dedicated server does, and answered with the registered fixtures.
+// The requests deleting, stopping and powering off a grid without a fixture
+// change the grids served, or are answered with 404 when the grid is not
+// served. Other requests without a fixture are answered with 404.
+type Handler struct {
+	key []byte
+
+	mu          sync.Mutex
+	fixtures    map[string]FixtureFunc
+	faults      map[string]Fault
+	globalFault Fault
+	counts      map[string]int
+	// The time each nonce was last used.
+	nonces map[int]time.Time
+}
+
+// Create and return a handler accepting requests signed with the given key.
+// The handler serves an empty world: the server is ready, and every list is
+// empty.
+func NewHandler(key []byte) *Handler {
+	h := &Handler{
+		key:      key,
+		fixtures: make(map[string]FixtureFunc),
+		faults:   make(map[string]Fault),
+		counts:   make(map[string]int),
+		nonces:   make(map[int]time.Time),
+	}
+
+	h.MustSetData("GET", "/v1/server/ping", vrage_client.PingResponseData{Result: "Pong"})
+	h.MustSetData("GET", "/v1/server", vrage_client.ServerResponseData{
+		IsReady:    true,
+		ServerName: "vragetest",
+		WorldName:  "vragetest",
+		SimSpeed:   1,
+	})
+	for path, name := range map[string]string{
+		"/v1/session/planets":         "Planets",
+		"/v1/session/asteroids":       "Asteroids",
+		"/v1/session/grids":           "Grids",
+		"/v1/session/players":         "Players",
+		"/v1/session/characters":      "Characters",
+		"/v1/session/chat":            "Messages",
+		"/v1/session/floatingObjects": "FloatingObjects",
+		"/v1/admin/bannedPlayers":     "BannedPlayers",
+		"/v1/admin/kickedPlayers":     "KickedPlayers",
+		"/v1/admin/cheaters":          "Cheaters",
+	} {
+		h.MustSetData("GET", path, map[string][]interface{}{name: {}})
+	}
+	h.MustSetData("PATCH", "/v1/session", nil)
+	h.MustSetData("DELETE", "/v1/server", nil)
+
+	return h
+}
+
+// Serve the given fixture.
+func (h *Handler) SetFixture(fixture Fixture) {
+	h.SetFunc(fixture.Method, fixture.Path, func(*http.Request) Fixture {
+		return fixture
+	})
+}
+
+// Serve the fixtures computed by the given function for the given method and
+// path.
+func (h *Handler) SetFunc(method string, path string, f FixtureFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.fixtures[routeKey(method, path)] = f
+}
+
+// Serve the given data for the given method and path.
+func (h *Handler) SetData(method string, path string, data interface{}) error {
+	fixture, err := NewFixture(method, path, data)
+	if err != nil {
+		return err
+	}
+	h.SetFixture(fixture)
+	return nil
+}
+
+// Like SetData, but panics if the data cannot be encoded.
+func (h *Handler) MustSetData(method string, path string, data interface{}) {
+	if err := h.SetData(method, path, data); err != nil {
+		panic(err)
+	}
+}
+
+// Stop serving the given method and path.
+func (h *Handler) Remove(method string, path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.fixtures, routeKey(method, path))
+}
+
+// Inject the given fault in the responses to the given method and path. The
+// zero Fault removes the fault.
+func (h *Handler) SetFault(method string, path string, fault Fault) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if fault == (Fault{}) {
+		delete(h.faults, routeKey(method, path))
+		return
+	}
+	h.faults[routeKey(method, path)] = fault
+}
+
+// Inject the given fault in the responses to every request without a fault of
+// its own. The zero Fault removes the fault.
+func (h *Handler) SetGlobalFault(fault Fault) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.globalFault = fault
+}
+
+// Returns the number of authenticated requests received for the given method
+// and path.
+func (h *Handler) Count(method string, path string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.counts[routeKey(method, path)]
+}
+
+// Check the Authorization header of the request. The header holds a nonce and
+// the HMAC-SHA1 of the request URI, the nonce and the Date header, as computed
+// by the client. Requests dated more than MaxDateSkew away from now, and
+// requests reusing a recent nonce, are rejected.
+func (h *Handler) authenticate(r *http.Request) int {
+	nonce, hash, ok := strings.Cut(r.Header.Get("Authorization"), ":")
+	if !ok {
+		return http.StatusUnauthorized
+	}
+	date := r.Header.Get("Date")
+	if date == "" {
+		return http.StatusUnauthorized
+	}
+	sent, err := http.ParseTime(date)
+	if err != nil {
+		return http.StatusUnauthorized
+	}
+	n, err := strconv.Atoi(nonce)
+	if err != nil {
+		return http.StatusUnauthorized
+	}
+
+	expected, err := vrage_client.GetHMAC(h.key, r.URL.RequestURI(), n, date)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	got, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil {
+		return http.StatusForbidden
+	}
+	want, _ := base64.StdEncoding.DecodeString(expected)
+	if !hmac.Equal(got, want) {
+		return http.StatusForbidden
+	}
+
+	now := time.Now()
+	if skew := now.Sub(sent); skew > MaxDateSkew || skew < -MaxDateSkew {
+		return http.StatusForbidden
+	}
+	if !h.useNonce(n, now) {
+		return http.StatusForbidden
+	}
+	return http.StatusOK
+}
+
+// Record the use of the given nonce at the given time. Returns false if the
+// nonce was already used within twice MaxDateSkew.
+func (h *Handler) useNonce(nonce int, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for n, used := range h.nonces {
+		if now.Sub(used) > 2*MaxDateSkew {
+			delete(h.nonces, n)
+		}
+	}
+	if _, ok := h.nonces[nonce]; ok {
+		return false
+	}
+	h.nonces[nonce] = now
+	return true
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status := h.authenticate(r); status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, BasePath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	key := routeKey(r.Method, path)
+
+	h.mu.Lock()
+	h.counts[key]++
+	f, ok := h.fixtures[key]
+	if !ok {
+		f, ok = h.gridAction(key)
+	}
+	fault, faulty := h.faults[key]
+	if !faulty {
+		fault = h.globalFault
+	}
+	h.mu.Unlock()
+
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fault.StatusCode != 0 {
+		http.Error(w, http.StatusText(fault.StatusCode), fault.StatusCode)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	fixture := f(r)
+	body := []byte(fixture.Body)
+	if fault.MalformedJSON {
+		body = append([]byte("{"), body[:len(body)/2]...)
+	}
+	if len(body) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	status := fixture.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// Returns the fixture function applying the grid action of the given route,
+// if any. Must be called with the lock held.
+func (h *Handler) gridAction(key string) (FixtureFunc, bool) {
+	for prefix, change := range gridActions {
+		id, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		entityId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+
+		return func(r *http.Request) Fixture {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			// The fixture function of the grids expects a request for
+			// the grids.
+			get := r.Clone(r.Context())
+			get.Method = "GET"
+			get.URL.Path = BasePath + "/v1/session/grids"
+
+			var resp vrage_client.GridResponse
+			if f, ok := h.fixtures[routeKey(get.Method, "/v1/session/grids")]; ok {
+				if err := json.Unmarshal(f(get).Body, &resp); err != nil {
+					return Fixture{StatusCode: http.StatusInternalServerError}
+				}
+			}
+
+			grids := resp.Data.Grids[:0]
+			found := false
+			for _, grid := range resp.Data.Grids {
+				if grid.EntityId == entityId {
+					found = true
+					if change == nil {
+						continue
+					}
+					change(&grid)
+				}
+				grids = append(grids, grid)
+			}
+			if !found {
+				return Fixture{StatusCode: http.StatusNotFound}
+			}
+
+			fixture, err := NewFixture("GET", "/v1/session/grids", map[string]interface{}{"Grids": grids})
+			if err != nil {
+				return Fixture{StatusCode: http.StatusInternalServerError}
+			}
+			h.fixtures[routeKey(fixture.Method, fixture.Path)] = func(*http.Request) Fixture { return fixture }
+			return Fixture{StatusCode: http.StatusOK}
+		}, true
+	}
+	return nil, false
+}
+
+// Serve the given fixtures in order, like vrage_client.NewReplayTransport
+// does. The other routes keep their fixtures.
+func (h *Handler) Replay(fixtures []Fixture) {
+	transport := vrage_client.NewReplayTransport(fixtures)
+	for _, fixture := range fixtures {
+		path := fixture.Path
+		h.SetFunc(fixture.Method, path, func(r *http.Request) Fixture {
+			resp, err := transport.RoundTrip(r)
+			if err != nil {
+				return Fixture{StatusCode: http.StatusInternalServerError}
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return Fixture{StatusCode: http.StatusInternalServerError}
+			}
+			return Fixture{Method: r.Method, Path: path, StatusCode: resp.StatusCode, Body: body}
+		})
+	}
+}
+
+// A fake remote API listening on a local port.
+type Server struct {
+	*Handler
+	*httptest.Server
+
+	// The base64-encoded secret key of the server.
+	Key string
+}
+
+// Start and return a fake remote API accepting requests signed with a fixed
+// key. The server must be closed once done.
+func NewServer() *Server {
+	key := []byte("vragetest secret key")
+	h := NewHandler(key)
+	return &Server{
+		Handler: h,
+		Server:  httptest.NewServer(h),
+		Key:     base64.StdEncoding.EncodeToString(key),
+	}
+}
+
+// Create and return a client of the server.
+func (s *Server) NewClient(logger log.Logger) (*vrage_client.VRageClient, error) {
+	return vrage_client.NewVRageClient(s.URL, "", s.Key, true, &logger)
+}
@@ -0,0 +1,89 @@
+package vragetest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+func TestUnsignedRequest(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + BasePath + "/v1/server/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", resp.StatusCode)
+	}
+}
+
+// Returns a request for the given path, signed with the key of the server,
+// the given nonce and the given date.
+func signedRequest(t *testing.T, srv *Server, path string, nonce int, date time.Time) *http.Request {
+	t.Helper()
+
+	key, err := vrage_client.DecodeSecretKey(srv.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted := date.UTC().Format(http.TimeFormat)
+	hash, err := vrage_client.GetHMAC(key, BasePath+path, nonce, formatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", srv.URL+BasePath+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Date", formatted)
+	req.Header.Set("Authorization", fmt.Sprintf("%d:%s", nonce, hash))
+	return req
+}
+
+// Returns the status code of the response to the given request.
+func statusCode(t *testing.T, req *http.Request) int {
+	t.Helper()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestStaleDate(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	for i, offset := range []time.Duration{-MaxDateSkew - time.Minute, MaxDateSkew + time.Minute} {
+		req := signedRequest(t, srv, "/v1/server/ping", i, time.Now().Add(offset))
+		if status := statusCode(t, req); status != http.StatusForbidden {
+			t.Errorf("date %s from now: expected status 403, got %d", offset, status)
+		}
+	}
+}
+
+func TestReplayedNonce(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	date := time.Now()
+	if status := statusCode(t, signedRequest(t, srv, "/v1/server/ping", 42, date)); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if status := statusCode(t, signedRequest(t, srv, "/v1/server/ping", 42, date)); status != http.StatusForbidden {
+		t.Errorf("replayed request: expected status 403, got %d", status)
+	}
+	if status := statusCode(t, signedRequest(t, srv, "/v1/server/ping", 43, date)); status != http.StatusOK {
+		t.Errorf("new nonce: expected status 200, got %d", status)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2023 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/thelande/space-engineers-exporter/pkg/collector"
+	"github.com/thelande/space-engineers-exporter/pkg/config"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+// Serves the metrics of arbitrary remote APIs, selected with the target and
+// module query parameters of each request.
+type probeHandler struct {
+	options collector.Options
+	// The maximum number of targets whose collector is kept.
+	maxTargets int
+
+	// Collectors are kept between probes so that the counters they
+	// maintain keep increasing.
+	mu      sync.Mutex
+	modules map[string]config.Module
+	targets map[string]*probeTarget
+}
+
+// The collector of a probed target.
+type probeTarget struct {
+	collector collector.Collector
+	client    *vrage_client.VRageClient
+	target    string
+	module    string
+	// The name of the target in the files of the exporter.
+	name     string
+	lastUsed time.Time
+}
+
+func newProbeHandler(options collector.Options, maxTargets int) *probeHandler {
+	return &probeHandler{
+		options:    options,
+		maxTargets: maxTargets,
+		targets:    make(map[string]*probeTarget),
+	}
+}
+
+// Replace the modules available to the probes. The collectors created with
+// the previous modules are discarded.
+func (h *probeHandler) setModules(modules map[string]config.Module) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, t := range h.targets {
+		t.client.CloseIdleConnections()
+	}
+	h.modules = modules
+	h.targets = make(map[string]*probeTarget)
+}
+
+// Forget the least recently probed target, along with its player session
+// state, so that probing arbitrary targets does not use memory and disk space
+// without bound. The recorded responses of the target are kept.
+func (h *probeHandler) evictOldest() {
+	var oldest string
+	for id, t := range h.targets {
+		if oldest == "" || t.lastUsed.Before(h.targets[oldest].lastUsed) {
+			oldest = id
+		}
+	}
+
+	t := h.targets[oldest]
+	delete(h.targets, oldest)
+	t.client.CloseIdleConnections()
+
+	if path := playerStateFile(t.name); path != "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			level.Error(logger).Log("msg", "Failed to remove the player session state of a probe target", "path", path, "err", err)
+		}
+	}
+	level.Debug(logger).Log("msg", "Evicted probe target", "target", t.target, "module", t.module)
+}
+
+// Returns the collector for the given target and module, creating it if
+// needed.
+func (h *probeHandler) getCollector(target string, moduleName string) (collector.Collector, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id := moduleName + "\x00" + target
+	if t, ok := h.targets[id]; ok {
+		t.lastUsed = time.Now()
+		return t.collector, nil
+	}
+
+	// The credentials given on the command line are not sent to arbitrary
+	// targets.
+	module, ok := h.modules[moduleName]
+	if !ok {
+		return collector.Collector{}, fmt.Errorf("unknown module %q", moduleName)
+	}
+
+	for len(h.targets) > 0 && len(h.targets) >= h.maxTargets {
+		h.evictOldest()
+	}
+
+	// The module and target are hashed, so that each pair gets its own
+	// files whatever the characters they contain.
+	name := fmt.Sprintf("probe_%x", sha256.Sum256([]byte(id)))
+	client, err := newClient(target, module, name)
+	if err != nil {
+		return collector.Collector{}, fmt.Errorf("failed to create client for module %q: %w", moduleName, err)
+	}
+
+	options := h.options
+	options.Players.StateFile = playerStateFile(name)
+	c := collector.NewCollector(client, logger, options)
+	h.targets[id] = &probeTarget{
+		collector: c,
+		client:    client,
+		target:    target,
+		module:    moduleName,
+		name:      name,
+		lastUsed:  time.Now(),
+	}
+	return c, nil
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	if params.Get("module") == "" {
+		http.Error(w, "module parameter is missing", http.StatusBadRequest)
+		return
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+
+	c, err := h.getCollector(target, params.Get("module"))
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to probe target", "target", target, "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filters := params["collect[]"]; len(filters) > 0 {
+		if c, err = c.Filter(filters); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	timeout, err := getScrapeTimeout(r)
+	if err != nil {
+		level.Error(logger).Log("msg", "Invalid scrape timeout", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c.WithContext(ctx))
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,445 @@
+/*
+Copyright 2023 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	config_util "github.com/prometheus/common/config"
+	"github.com/thelande/space-engineers-exporter/pkg/collector"
+	"github.com/thelande/space-engineers-exporter/pkg/config"
+	vrage_client "github.com/thelande/space-engineers-exporter/pkg/vrage_client"
+)
+
+// Create a client for the remote API at the given URL using the credentials
+// of the given module. The name identifies the server in the directories of
+// the recorded responses.
+func newClient(url string, module config.Module, name string) (*vrage_client.VRageClient, error) {
+	tlsConfig, err := config_util.NewTLSConfig(&module.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := vrage_client.NewVRageClientWithTLSConfig(url, module.KeyFile, module.Key, tlsConfig, &logger)
+	if err != nil {
+		return nil, err
+	}
+	client.SetRetryPolicy(vrage_client.RetryPolicy{
+		MaxRetries:     *retries,
+		InitialBackoff: *retryBackoff,
+		MaxBackoff:     *retryMaxBackoff,
+	})
+	client.SetCircuitBreaker(*circuitBreakerThreshold, *circuitBreakerCooldown)
+
+	switch {
+	case *recordDir != "":
+		var recordErr error
+		client.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			recorder, err := vrage_client.NewRecorder(serverPath(*recordDir, name), next, logger)
+			if err != nil {
+				recordErr = err
+				return next
+			}
+			return recorder
+		})
+		if recordErr != nil {
+			return nil, fmt.Errorf("failed to record responses: %w", recordErr)
+		}
+	case *replayDir != "":
+		fixtures, err := vrage_client.LoadFixtures(serverPath(*replayDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load recorded responses: %w", err)
+		}
+		client.WrapTransport(func(http.RoundTripper) http.RoundTripper {
+			return vrage_client.NewReplayTransport(fixtures)
+		})
+	}
+
+	return client, nil
+}
+
+// Read and validate the configuration file, including the parts that depend
+// on the collectors known to the exporter.
+func loadConfig(path string) (*config.Config, error) {
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, name := range collector.Names() {
+		known[name] = true
+	}
+	// The labels of the servers are attached to every metric, so they must
+	// not clash with the labels of the metrics themselves.
+	reserved := make(map[string]bool)
+	for _, name := range collector.LabelNames() {
+		reserved[name] = true
+	}
+	for i := range cfg.Servers {
+		for _, name := range cfg.Servers[i].Collectors {
+			if !known[name] {
+				return nil, fmt.Errorf("server %q: unknown collector %q, must be one of %s", cfg.Servers[i].Name, name, strings.Join(collector.Names(), ", "))
+			}
+		}
+		for name := range cfg.Servers[i].Labels {
+			if reserved[name] {
+				return nil, fmt.Errorf("server %q: label %q is already used by the metrics of the exporter", cfg.Servers[i].Name, name)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// Returns the server defined by the command-line flags, which is used when
+// the configuration file does not define any servers.
+func flagServer() config.Server {
+	return config.Server{
+		URL: *api,
+		Module: config.Module{
+			Key:       *key,
+			KeyFile:   *keyFile,
+			TLSConfig: config_util.TLSConfig{InsecureSkipVerify: !*sslVerify},
+		},
+	}
+}
+
+// Returns the secret key of the module, read from its key file if any.
+func moduleKey(module config.Module) (string, error) {
+	if module.KeyFile == "" {
+		return module.Key, nil
+	}
+	data, err := os.ReadFile(module.KeyFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// A dedicated server whose metrics are exposed on the metrics endpoint.
+type target struct {
+	config config.Server
+	// The secret key in use, to notice a rotated key file on reload.
+	key       string
+	labels    prometheus.Labels
+	options   collector.Options
+	client    *vrage_client.VRageClient
+	collector collector.Collector
+	poller    *collector.Poller
+	cancel    context.CancelFunc
+	// Closed when the poller returns.
+	done chan struct{}
+	// The scrapes in progress.
+	scrapes sync.WaitGroup
+}
+
+// Create a target for the given server. The target does not query the server
+// until it is started.
+func newTarget(server config.Server, key string, options collector.Options) (*target, error) {
+	name := server.Name
+	if name == "" {
+		name = "default"
+	}
+	// The key read by the caller is used so that it matches the one
+	// compared on reload.
+	module := server.Module
+	module.Key, module.KeyFile = key, ""
+	client, err := newClient(server.URL, module, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The server defined on the command line keeps its metrics unlabelled.
+	labels := prometheus.Labels{}
+	if server.Name != "" {
+		for name, value := range server.MetricLabels() {
+			labels[string(name)] = string(value)
+		}
+	}
+
+	if len(server.Collectors) > 0 {
+		options.Collectors = server.Collectors
+	}
+	options.Players.StateFile = playerStateFile(name)
+	return &target{
+		config:  server,
+		key:     key,
+		labels:  labels,
+		options: options,
+		client:  client,
+	}, nil
+}
+
+// Create the collector of the target, loading the player session state, and
+// start polling the server in the background if enabled.
+func (t *target) start() {
+	t.collector = collector.NewCollector(t.client, logger, t.options)
+
+	if *pollInterval > 0 {
+		var ctx context.Context
+		ctx, t.cancel = context.WithCancel(context.Background())
+		t.poller = collector.NewPoller(t.collector, *pollInterval, *apiTimeout)
+		t.done = make(chan struct{})
+		go func() {
+			defer close(t.done)
+			t.poller.Run(ctx)
+		}()
+	}
+}
+
+// Stop the background polling of the target, if any, and wait for the polls
+// and scrapes in progress so that the target no longer writes its player
+// session state. The idle connections of its client are closed.
+func (t *target) stop() {
+	if t.cancel != nil {
+		t.cancel()
+		<-t.done
+	}
+	t.scrapes.Wait()
+	t.client.CloseIdleConnections()
+}
+
+// Returns the collector of the target, bounding its queries by the given
+// context and restricting it to the given collectors, if any. The given
+// collectors that are disabled on the target are skipped, so that a scrape of
+// several servers may select collectors enabled on some of them only.
+func (t *target) scrapeCollector(ctx context.Context, filters []string) (prometheus.Collector, error) {
+	if t.poller != nil {
+		if len(filters) > 0 {
+			return nil, errors.New("collect[] is not supported when polling the remote API in the background")
+		}
+		return t.poller, nil
+	}
+
+	c := t.collector
+	if len(filters) > 0 {
+		enabled := make(map[string]bool)
+		for _, name := range t.options.Collectors {
+			enabled[name] = true
+		}
+		known := make(map[string]bool)
+		for _, name := range collector.Names() {
+			known[name] = true
+		}
+
+		// The unknown collectors are kept for Filter to reject them.
+		names := []string{}
+		for _, name := range filters {
+			if enabled[name] || !known[name] {
+				names = append(names, name)
+			}
+		}
+		var err error
+		if c, err = c.Filter(names); err != nil {
+			return nil, err
+		}
+	}
+	return c.WithContext(ctx), nil
+}
+
+// The set of servers served by the exporter, which is replaced when the
+// configuration is reloaded.
+type targetSet struct {
+	options collector.Options
+	probe   *probeHandler
+
+	mu      sync.RWMutex
+	targets []*target
+}
+
+// Load the configuration file, if any, and replace the targets and probe
+// modules accordingly. The current targets are kept if the configuration is
+// invalid, and targets whose configuration did not change keep their state.
+// The replaced targets are stopped before their replacements start, since
+// they may share a player session state file.
+func (s *targetSet) reload() error {
+	cfg := &config.Config{}
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			return err
+		}
+	}
+
+	servers := cfg.Servers
+	if len(servers) == 0 {
+		servers = []config.Server{flagServer()}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := make(map[string]*target)
+	for _, t := range s.targets {
+		current[t.config.Name] = t
+	}
+
+	var targets []*target
+	reused := make(map[*target]bool)
+	for _, server := range servers {
+		// The key file is read again, so that a rotated key is used
+		// without restarting the exporter.
+		key, err := moduleKey(server.Module)
+		if t, ok := current[server.Name]; ok && err == nil && t.key == key && reflect.DeepEqual(t.config, server) {
+			targets = append(targets, t)
+			reused[t] = true
+			continue
+		}
+
+		var t *target
+		if err == nil {
+			t, err = newTarget(server, key, s.options)
+		}
+		if err != nil {
+			for _, t := range targets {
+				if !reused[t] {
+					t.client.CloseIdleConnections()
+				}
+			}
+			if server.Name == "" {
+				return err
+			}
+			return fmt.Errorf("server %q: %w", server.Name, err)
+		}
+		targets = append(targets, t)
+	}
+
+	for _, t := range s.targets {
+		if !reused[t] {
+			t.stop()
+		}
+	}
+	for _, t := range targets {
+		if !reused[t] {
+			t.start()
+		}
+	}
+	s.targets = targets
+	s.probe.setModules(cfg.Modules)
+
+	return nil
+}
+
+// Returns the target with the given name. The name may be omitted when there
+// is only one target.
+func (s *targetSet) get(name string) (*target, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if name == "" {
+		if len(s.targets) == 1 {
+			return s.targets[0], nil
+		}
+		return nil, errors.New("server parameter is missing")
+	}
+
+	for _, t := range s.targets {
+		if t.config.Name == name {
+			return t, nil
+		}
+	}
+
+	var names []string
+	for _, t := range s.targets {
+		names = append(names, t.config.Name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown server %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+// Returns a handler collecting the metrics of all targets, bounding the
+// collection by the scrape timeout.
+func (s *targetSet) metricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, err := getScrapeTimeout(r)
+		if err != nil {
+			level.Error(logger).Log("msg", "Invalid scrape timeout", "err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		filters := r.URL.Query()["collect[]"]
+		registry := prometheus.NewRegistry()
+		s.mu.RLock()
+		// The targets replaced by a reload wait for the scrape to complete.
+		for _, t := range s.targets {
+			t.scrapes.Add(1)
+			defer t.scrapes.Done()
+		}
+		for _, t := range s.targets {
+			c, err := t.scrapeCollector(ctx, filters)
+			if err != nil {
+				s.mu.RUnlock()
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := prometheus.WrapRegistererWith(t.labels, registry).Register(c); err != nil {
+				s.mu.RUnlock()
+				level.Error(logger).Log("msg", "Failed to register collector", "server", t.config.Name, "err", err)
+				http.Error(w, fmt.Sprintf("failed to register collector: %s", err), http.StatusInternalServerError)
+				return
+			}
+		}
+		s.mu.RUnlock()
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// Returns a handler passing the request to the handler of the target selected
+// by the server query parameter.
+func (s *targetSet) targetHandler(handler func(c collector.Collector) http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, err := s.get(r.URL.Query().Get("server"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handler(t.collector).ServeHTTP(w, r)
+	})
+}
+
+// Returns a handler reloading the configuration on POST requests.
+func (s *targetSet) reloadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := s.reload(); err != nil {
+			level.Error(logger).Log("msg", "Failed to reload configuration", "err", err)
+			http.Error(w, fmt.Sprintf("failed to reload configuration: %s", err), http.StatusInternalServerError)
+			return
+		}
+		level.Info(logger).Log("msg", "Reloaded configuration")
+	})
+}